@@ -0,0 +1,725 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jtompkin/goclacker/internal/stack"
+)
+
+// option is a setting that can be given as a command line flag, as an
+// environment variable, or in a config file, in that order of precedence.
+type option struct {
+	// name is the key of the option in a config file.
+	name string
+	// flags are the command line flags that set the option.
+	flags []string
+	// env is the environment variable that sets the option.
+	env string
+	// fromEnv, if not nil, turns the value of env into a value for set.
+	fromEnv func(value string) string
+	// set sets the option from a string, and get returns it as one.
+	set func(value string) error
+	get func() string
+	// source is where the value of the option came from.
+	source string
+}
+
+func boolOption(name string, flags []string, env string, p *bool) *option {
+	return &option{
+		name: name, flags: flags, env: env, source: "default",
+		set: func(value string) (err error) {
+			*p, err = strconv.ParseBool(value)
+			return err
+		},
+		get: func() string { return strconv.FormatBool(*p) },
+	}
+}
+
+func intOption(name string, flags []string, env string, p *int) *option {
+	return &option{
+		name: name, flags: flags, env: env, source: "default",
+		set: func(value string) (err error) {
+			*p, err = strconv.Atoi(value)
+			return err
+		},
+		get: func() string { return strconv.Itoa(*p) },
+	}
+}
+
+func stringOption(name string, flags []string, env string, p *string) *option {
+	return &option{
+		name: name, flags: flags, env: env, source: "default",
+		set: func(value string) error {
+			*p = value
+			return nil
+		},
+		get: func() string { return strconv.Quote(*p) },
+	}
+}
+
+// options are every option that a config file can set, in the order they are
+// listed in the usage.
+var options = []*option{
+	boolOption("strict", []string{"s", "strict"}, "GOCLACKER_STRICT", &StrictMode),
+	boolOption("display", []string{"d", "no-display"}, "GOCLACKER_DISPLAY", &Display),
+	boolOption("color", []string{"r", "no-color"}, "NO_COLOR", &Color),
+	intOption("limit", []string{"l", "limit"}, "GOCLACKER_LIMIT", &StackLimit),
+	stringOption("prompt", []string{"p", "prompt"}, "GOCLACKER_PROMPT", &PromptFmt),
+	stringOption("right-prompt", []string{"P", "right-prompt"}, "GOCLACKER_RIGHT_PROMPT", &RightPromptFmt),
+	stringOption("save-file", []string{"save-file"}, "GOCLACKER_SAVE_FILE", &SaveFile),
+	boolOption("autosave", []string{"autosave"}, "GOCLACKER_AUTOSAVE", &Autosave),
+	stringOption("session", []string{"session"}, "GOCLACKER_SESSION", &SessionName),
+	{
+		name: "seed", flags: []string{"seed"}, env: "GOCLACKER_SEED", source: "default",
+		set: func(value string) (err error) {
+			Seed, err = strconv.ParseInt(value, 10, 64)
+			Seeded = err == nil
+			return err
+		},
+		get: func() string {
+			if !Seeded {
+				return "none"
+			}
+			return strconv.FormatInt(Seed, 10)
+		},
+	},
+}
+
+func init() {
+	// Any value of NO_COLOR turns color off; see https://no-color.org.
+	lookupOption("color").fromEnv = func(string) string { return "false" }
+}
+
+func lookupOption(name string) *option {
+	for _, opt := range options {
+		if opt.name == name {
+			return opt
+		}
+	}
+	return nil
+}
+
+// givenFlag returns the name of the flag of opt that was given on the command
+// line, or an empty string if none were.
+func (opt *option) givenFlag() (given string) {
+	flag.Visit(func(f *flag.Flag) {
+		if slices.Contains(opt.flags, f.Name) {
+			given = f.Name
+		}
+	})
+	return given
+}
+
+// flagSource returns the source of an option given as the flag name.
+func flagSource(name string) string {
+	if len(name) == 1 {
+		return "flag -" + name
+	}
+	return "flag --" + name
+}
+
+// ApplyOptions sets every option that was not given as a command line flag
+// from the environment or else from conf, which may be nil, and records where
+// each came from.
+func ApplyOptions(conf *Config) (msg string) {
+	sb := new(strings.Builder)
+	for _, opt := range options {
+		if name := opt.givenFlag(); name != "" {
+			opt.source = flagSource(name)
+			continue
+		}
+		if value := os.Getenv(opt.env); value != "" {
+			if opt.fromEnv != nil {
+				value = opt.fromEnv(value)
+			}
+			if err := opt.set(value); err != nil {
+				fmt.Fprintf(sb, "could not set %s to %s from %s : invalid value\n", opt.name, value, opt.env)
+				continue
+			}
+			opt.source = "environment variable " + opt.env
+			continue
+		}
+		if conf == nil {
+			continue
+		}
+		for _, s := range conf.Settings {
+			if s.name != opt.name {
+				continue
+			}
+			if err := opt.set(s.def); err != nil {
+				fmt.Fprintf(sb, "could not set %s to %s : invalid value\n", s.name, s.def)
+				continue
+			}
+			opt.source = "config file " + s.path
+		}
+	}
+	return sb.String()
+}
+
+// loadedConfig is the config that goclacker started with, which may be nil.
+var loadedConfig *Config
+
+// ShowConfig is an Action that prints the value of every option and where it
+// came from.
+var ShowConfig = stack.NewAction(
+	func(so *stack.StackOperator) (string, error) {
+		sb := new(strings.Builder)
+		if loadedConfig == nil || len(loadedConfig.Files) == 0 {
+			sb.WriteString("config = none\n")
+		} else {
+			for _, f := range loadedConfig.Files {
+				fmt.Fprintf(sb, "config = %q (%s)\n", f.path, f.source)
+			}
+		}
+		for _, opt := range options {
+			fmt.Fprintf(sb, "%s = %s (%s)\n", opt.name, opt.get(), opt.source)
+		}
+		return sb.String(), nil
+	}, 0, 0,
+	"Print the value of every setting and where it came from.",
+)
+
+// configFile is the path to a config file and where that path came from.
+type configFile struct {
+	path, source string
+}
+
+// layers are the names of the default config files, in the order they are
+// read.
+var layers = []string{"system", "user", "home", "saved", "project"}
+
+// configLayers returns the path to each default config file, named by layers.
+// Layers that cannot be found, like the ones in the home directory when there
+// is no home directory, are left out.
+func configLayers() map[string]string {
+	paths := make(map[string]string, len(layers))
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("ProgramData"); dir != "" {
+			paths["system"] = filepath.Join(dir, "goclacker", "config")
+		}
+	} else {
+		paths["system"] = "/etc/goclacker/config"
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths["user"] = filepath.Join(dir, "goclacker", "config")
+		paths["saved"] = filepath.Join(dir, "goclacker", "saved")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths["home"] = filepath.Join(home, ".goclacker")
+	}
+	paths["project"] = ".goclacker"
+	return paths
+}
+
+// sessionDir returns the directory that sessions are saved in: goclacker/sessions
+// in $XDG_DATA_HOME, which is ~/.local/share if not set, or in the local app
+// data directory on Windows. It returns an empty string if there is no such
+// directory.
+func sessionDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" && runtime.GOOS == "windows" {
+		dir = os.Getenv("LocalAppData")
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "goclacker", "sessions")
+}
+
+// absPath returns the absolute path of path, or path if it has none.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// ConfigFiles returns the config files to read. That is the file given with
+// the -c flag or the GOCLACKER_CONFIG environment variable if there is one, or
+// else every default config file that exists, except for the layers named in
+// the GOCLACKER_SKIP_CONFIG environment variable.
+func ConfigFiles() []configFile {
+	if ConfigPath != "\x00" {
+		if ConfigPath == "" {
+			return nil
+		}
+		source := ""
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "c" || f.Name == "config" {
+				source = flagSource(f.Name)
+			}
+		})
+		return []configFile{{ConfigPath, source}}
+	}
+	if path, found := os.LookupEnv("GOCLACKER_CONFIG"); found {
+		if path == "" {
+			return nil
+		}
+		return []configFile{{path, "environment variable GOCLACKER_CONFIG"}}
+	}
+	skip := strings.Split(os.Getenv("GOCLACKER_SKIP_CONFIG"), ",")
+	for i := range skip {
+		skip[i] = strings.TrimSpace(skip[i])
+	}
+	paths := configLayers()
+	files := make([]configFile, 0, len(layers))
+	seen := make([]string, 0, len(layers))
+	for _, name := range layers {
+		path, found := paths[name]
+		if !found || slices.Contains(skip, name) {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		// The home and project layers are the same file when goclacker is
+		// run from the home directory.
+		abs := absPath(path)
+		if slices.Contains(seen, abs) {
+			continue
+		}
+		seen = append(seen, abs)
+		files = append(files, configFile{path, name + " layer"})
+	}
+	return files
+}
+
+// definition is a setting, word, or value word defined in a config file.
+type definition struct {
+	name, def string
+	// path is the config file that the definition is in.
+	path string
+}
+
+// Config is the contents of a config file.
+type Config struct {
+	// Settings are the values of options, by name, in the order they were set.
+	Settings []definition
+	// Units, Words, and Values are the units, words, and value words to
+	// define, in order.
+	Units, Words, Values []definition
+	// Programs are run after defining every word.
+	Programs []string
+	// Includes are the paths of other config files to read first.
+	Includes []string
+	// Files are the config files that were read, in order.
+	Files []configFile
+}
+
+// unquote returns s without a single pair of surrounding double quotes, which
+// are optional in config files.
+func unquote(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+}
+
+// sections are the sections of a structured config file.
+var sections = []string{"units", "words", "values", "programs"}
+
+// structuredMarker is a first line that marks a config file as structured even
+// if it has nothing else in it.
+const structuredMarker = "# goclacker config"
+
+// isStructured reports whether lines are a structured config file: one that
+// starts with structuredMarker, or whose first line that is not blank or a
+// comment is a known setting or section. Anything else is the legacy format,
+// where the first line is always the prompt format, even if it looks like a
+// comment or a section.
+func isStructured(lines []string) bool {
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == structuredMarker {
+		return true
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			return slices.Contains(sections, line[1:len(line)-1])
+		}
+		key, _, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		return found && (key == "include" || lookupOption(key) != nil)
+	}
+	return false
+}
+
+// parseLegacy parses lines in the legacy format: the first line is the prompt
+// format, and every other line is a program.
+func parseLegacy(lines []string) *Config {
+	conf := new(Config)
+	if len(lines) == 0 {
+		return conf
+	}
+	if lines[0] != "" {
+		conf.Settings = append(conf.Settings, definition{name: "prompt", def: unquote(lines[0])})
+	}
+	for _, line := range lines[1:] {
+		conf.Programs = append(conf.Programs, strings.TrimSpace(line))
+	}
+	return conf
+}
+
+// parseStructured parses lines in the structured format:
+//
+//	# comment
+//	include = ~/base.conf
+//	limit = 16
+//	prompt = " &c > "
+//
+//	[units]
+//	furlong = 201.168_m
+//
+//	[words]
+//	sqrt = 0.5 ^
+//
+//	[values]
+//	g = 9.81
+//
+//	[programs]
+//	deg-mode
+//
+// It returns a message for every line that could not be parsed.
+func parseStructured(lines []string) (conf *Config, msg string) {
+	conf = new(Config)
+	sb := new(strings.Builder)
+	section := ""
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			if !slices.Contains(sections, section) {
+				fmt.Fprintf(sb, "config line %d : unknown section %s\n", i+1, line)
+			}
+			continue
+		}
+		if section == "programs" {
+			conf.Programs = append(conf.Programs, line)
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || key == "" {
+			fmt.Fprintf(sb, "config line %d : expected key = value : %s\n", i+1, line)
+			continue
+		}
+		switch section {
+		case "":
+			if key == "include" {
+				conf.Includes = append(conf.Includes, unquote(value))
+				continue
+			}
+			if lookupOption(key) == nil {
+				fmt.Fprintf(sb, "config line %d : unknown setting %s\n", i+1, key)
+				continue
+			}
+			conf.Settings = append(conf.Settings, definition{name: key, def: unquote(value)})
+		case "units":
+			conf.Units = append(conf.Units, definition{name: key, def: value})
+		case "words":
+			conf.Words = append(conf.Words, definition{name: key, def: value})
+		case "values":
+			conf.Values = append(conf.Values, definition{name: key, def: value})
+		}
+	}
+	return conf, sb.String()
+}
+
+// readLines returns every line in the file at path.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// includePath returns the path of a file included by the config file at from.
+// A leading ~ is the home directory, and relative paths are relative to the
+// directory that from is in.
+func includePath(from, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// read reads the config file f, in either the structured or the legacy format,
+// after the files it includes, and adds it to conf. chain is the absolute paths
+// of the files that included f.
+func (conf *Config) read(f configFile, chain []string, sb *strings.Builder) {
+	abs := absPath(f.path)
+	if slices.Contains(chain, abs) {
+		fmt.Fprintf(sb, "could not include config file %s : it includes itself\n", f.path)
+		return
+	}
+	lines, err := readLines(f.path)
+	if err != nil {
+		fmt.Fprintf(sb, "could not read config file : %v\n", err)
+		return
+	}
+	fmt.Fprintf(sb, "parsing config file... %s\n", f.path)
+	var c *Config
+	if isStructured(lines) {
+		var errs string
+		c, errs = parseStructured(lines)
+		sb.WriteString(errs)
+	} else {
+		c = parseLegacy(lines)
+	}
+	conf.Files = append(conf.Files, f)
+	for _, path := range c.Includes {
+		conf.read(configFile{includePath(f.path, path), "included by " + f.path}, append(chain, abs), sb)
+	}
+	for i := range c.Settings {
+		c.Settings[i].path = f.path
+	}
+	conf.Settings = append(conf.Settings, c.Settings...)
+	conf.Units = append(conf.Units, c.Units...)
+	conf.Words = append(conf.Words, c.Words...)
+	conf.Values = append(conf.Values, c.Values...)
+	conf.Programs = append(conf.Programs, c.Programs...)
+}
+
+// ReadConfig reads every config file in files, in order, so that each one can
+// override the settings and words of the ones before it. It returns a nil
+// Config if no files could be read.
+func ReadConfig(files []configFile) (conf *Config, msg string) {
+	conf = new(Config)
+	sb := new(strings.Builder)
+	for _, f := range files {
+		conf.read(f, nil, sb)
+	}
+	if len(conf.Files) == 0 {
+		return nil, sb.String()
+	}
+	return conf, sb.String()
+}
+
+// Load defines the units, words, and value words in conf and then runs its
+// programs.
+func (conf *Config) Load(so *stack.StackOperator) (msg string) {
+	if conf == nil {
+		return ""
+	}
+	conf.load(so, os.Stderr)
+	return "sucessfully parsed config file\n"
+}
+
+// load is Load, but it writes errors to w.
+func (conf *Config) load(so *stack.StackOperator, w io.Writer) {
+	programs := make([]string, 0, len(conf.Units)+len(conf.Words)+len(conf.Values)+len(conf.Programs))
+	for _, u := range conf.Units {
+		programs = append(programs, fmt.Sprintf("=u %s %s", u.name, u.def))
+	}
+	for _, w := range conf.Words {
+		programs = append(programs, fmt.Sprintf("= %s %s", w.name, w.def))
+	}
+	for _, v := range conf.Values {
+		programs = append(programs, fmt.Sprintf("== %s %s", v.name, v.def))
+	}
+	for _, p := range append(programs, conf.Programs...) {
+		if err := so.ParseInput(p); err != nil {
+			fmt.Fprint(w, err)
+		}
+	}
+}
+
+// baseline is what the config files other than the save file give goclacker:
+// a StackOperator with their units, words, and value words, and the options
+// they set. Save only writes what is different from it, so that the save file
+// does not copy, and then override, the other config files.
+var baseline struct {
+	so       *stack.StackOperator
+	settings map[string]string
+}
+
+// loadBaseline sets baseline from every file in files except the save file.
+func loadBaseline(files []configFile) {
+	save := absPath(SaveFile)
+	others := slices.DeleteFunc(slices.Clone(files), func(f configFile) bool {
+		return absPath(f.path) == save
+	})
+	// Errors were already printed when the config files were loaded.
+	conf, _ := ReadConfig(others)
+	baseline.so = GetStackOperator(false)
+	baseline.settings = make(map[string]string)
+	if conf == nil {
+		return
+	}
+	conf.load(baseline.so, io.Discard)
+	for _, s := range conf.Settings {
+		baseline.settings[s.name] = s.def
+	}
+}
+
+// sortedKeys returns the keys of m that are not in def or have a different
+// value there, and the keys of def that are not in m, in order.
+func sortedKeys(m, def map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if d, found := def[k]; !found || d != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range def {
+		if _, found := m[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// literals returns the literal of every value in m. Values are compared by
+// their literals because not every Value is comparable.
+func literals(m map[string]stack.Value) map[string]string {
+	lits := make(map[string]string, len(m))
+	for k, v := range m {
+		lits[k] = stack.Literal(v)
+	}
+	return lits
+}
+
+// Save returns a config file that gives a new StackOperator the units, words,
+// and value words of so that the other config files do not, and deletes the
+// ones that so deleted, by giving them no definition. If all is true,
+// the config file also restores the angle mode, stash, and TVM registers of so,
+// and keeps the prompt and right prompt if they were set by the save file.
+// Prompts given as flags or environment variables are never saved.
+func Save(so *stack.StackOperator, all bool) string {
+	def := baseline.so
+	if def == nil {
+		def = GetStackOperator(false)
+	}
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%s\n# Saved by goclacker %s; saving again replaces this file.\n", structuredMarker, Version)
+	prompts := []struct{ name, value string }{{"prompt", PromptFmt}, {"right-prompt", RightPromptFmt}}
+	for _, p := range prompts {
+		if !all || !strings.HasPrefix(lookupOption(p.name).source, "config file ") {
+			continue
+		}
+		if p.value != baseline.settings[p.name] {
+			fmt.Fprintf(sb, "%s = \"%s\"\n", p.name, p.value)
+		}
+	}
+	sections := []struct {
+		name    string
+		defs    map[string]string
+		defDefs map[string]string
+	}{
+		{"units", literals(so.Units), literals(def.Units)},
+		{"words", so.Words, def.Words},
+		{"values", literals(so.ValWords), literals(def.ValWords)},
+	}
+	for _, section := range sections {
+		keys := sortedKeys(section.defs, section.defDefs)
+		if len(keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(sb, "\n[%s]\n", section.name)
+		for _, k := range keys {
+			fmt.Fprintln(sb, strings.TrimSpace(fmt.Sprintf("%s = %s", k, section.defs[k])))
+		}
+	}
+	if !all {
+		return sb.String()
+	}
+	programs := make([]string, 0)
+	if so.Angle != def.Angle {
+		programs = append(programs, strings.ToLower(so.Angle.String())+"-mode")
+	}
+	if stack.Literal(so.Stack.Stash) != stack.Literal(def.Stack.Stash) {
+		programs = append(programs, stack.Literal(so.Stack.Stash)+" stash")
+	}
+	registers := []struct {
+		name     string
+		val, def float64
+	}{
+		{"N", so.TVM.N, def.TVM.N}, {"I/YR", so.TVM.I, def.TVM.I}, {"PV", so.TVM.PV, def.TVM.PV},
+		{"PMT", so.TVM.PMT, def.TVM.PMT}, {"FV", so.TVM.FV, def.TVM.FV}, {"P/YR", so.TVM.PY, def.TVM.PY},
+	}
+	for _, r := range registers {
+		if r.val != r.def {
+			programs = append(programs, fmt.Sprintf("%v %s", r.val, r.name))
+		}
+	}
+	if so.TVM.Begin {
+		programs = append(programs, "BEG")
+	}
+	if len(programs) > 0 {
+		fmt.Fprintf(sb, "\n[programs]\n%s\n", strings.Join(programs, "\n"))
+	}
+	return sb.String()
+}
+
+// WriteSave writes the config file returned by Save to path, replacing the file
+// that is there, so that it is read the next time goclacker starts.
+func WriteSave(so *stack.StackOperator, path string, all bool) (msg string, err error) {
+	if path == "" {
+		return "", errors.New("could not save : no save file\n")
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("could not save to %s : %v\n", path, err)
+	}
+	// Write to a temporary file first so that a failed save does not destroy
+	// the last one.
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, []byte(Save(so, all)), 0o644); err != nil {
+		return "", fmt.Errorf("could not save to %s : %v\n", path, err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("could not save to %s : %v\n", path, err)
+	}
+	if all {
+		return fmt.Sprintf("saved words and state to %s\n", path), nil
+	}
+	return fmt.Sprintf("saved words to %s\n", path), nil
+}
+
+// saveAction returns an Action that saves to the save file like WriteSave. It
+// is a function instead of a variable because saving needs GetStackOperator,
+// which uses the Action.
+func saveAction(all bool) *stack.Action {
+	help := "Save words, value words, and units to the save file."
+	if all {
+		help = "Save words, value words, units, the angle mode, the stash, and the TVM registers to the save file."
+	}
+	return stack.NewAction(
+		func(so *stack.StackOperator) (string, error) {
+			return WriteSave(so, SaveFile, all)
+		}, 0, 0, help,
+	)
+}
@@ -4,7 +4,7 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -12,6 +12,7 @@ import (
 	"math"
 	"os"
 	"strings"
+	"unicode"
 
 	"github.com/jtompkin/goclacker/internal/stack"
 )
@@ -20,7 +21,8 @@ const Usage string = `goclacker <version>
 by Josh Tompkin
 
 usage of goclacker:
-goclacker [-V] [-h] [-s] [-d] [-r] [-l] int [-c] string [-p] string [program]...
+goclacker [-V] [-h] [-s] [-d] [-r] [-l] int [-c] string [-p] string [-P] string [--seed] int [--save-file] string [--autosave]
+          [--session] string [program]...
     -V, --version
         Print version information and exit.
     -h, --help
@@ -31,25 +33,75 @@ goclacker [-V] [-h] [-s] [-d] [-r] [-l] int [-c] string [-p] string [program]...
     -d, --no-display
         Do not display stack after operations: useful if '&Nt' is in prompt.
     -r, --no-color
-        Do not color output in interactive mode.
+        Do not color output or the prompt in interactive mode.
     -l, --limit int
         Provide the stack size limit. There is no limit if a negative number is
         provided. (default 8)
     -c, --config string
-        Provide the path to the config file to use. Goclacker looks in the
-        default locations if not provided; provide an empty string to not use
-        default config files.
+        Provide the path to the config file to use. Goclacker reads every
+        default config file that exists if not provided; provide an empty
+        string to not use default config files.
     -p, --prompt string
         Provide the format string for the interactive prompt. (default " &c > ")
-        format specifiers:
+        format specifiers, which take an optional width and precision
+        (like &8.3t):
             &l  : stack limit
             &c  : current stack size
             &Nt : top N stack values
             &s  : current stash value
+            &a  : angle mode
+            &e  : error from the last line, if there was one
+            &r  : top value after the last line that worked
+            &d  : whether the stack is displayed (on or off)
+            &w  : number of defined words
+            &u  : time since goclacker started
+            &n  : name of the current session, if there is one
+            &v{name} : value of value word or constant name
+        conditionals:
+            &?{cond}{then}{else} : then if cond is not empty or 0, else if not
+            &?{a=b}{then}{else}  : then if a and b are the same
+            &?!{cond}{then}      : then if cond is empty or 0
+        styles, printed unless colors are off:
+            &{red}, &{bg:blue}, &{bright-green}, &{bold}, &{reset}, ...
+            &{208}, &{bg:208} : 256-color palette
+            &{#ff8800}        : true color
+        escapes: \\ for \, \& for &, \{ and \} for braces, \n for a new line
+        Use \n to put lines above the line that input is typed on.
+    -P, --right-prompt string
+        Provide the format string for a prompt shown at the right edge of the
+        line that input is typed on. It takes the same specifiers as --prompt.
+    --seed int
+        Seed the random number generator, so that the random operators give the
+        same numbers every time. Seeded from the current time if not provided.
+    --save-file string
+        Provide the path to the file that the save and save-words operators
+        write to. (default goclacker/saved in the user config directory, which
+        is read as a config file when goclacker starts)
+    --autosave
+        Save like the save operator when leaving interactive mode.
+    --session string
+        Start from the saved session with this name, or a new one if there is
+        none, and save it after every line entered in interactive mode. Sessions
+        are saved in goclacker/sessions in $XDG_DATA_HOME (~/.local/share).
     [program]...
         Any positional arguments will be interpreted and executed by the
         calculator. Interactive mode will not be entered if any positional
         arguments are supplied.
+
+environment variables:
+    GOCLACKER_CONFIG, GOCLACKER_STRICT, GOCLACKER_DISPLAY, GOCLACKER_LIMIT,
+    GOCLACKER_PROMPT, GOCLACKER_RIGHT_PROMPT, GOCLACKER_SEED,
+    GOCLACKER_SAVE_FILE, GOCLACKER_AUTOSAVE, GOCLACKER_SESSION
+        Set the same thing as the matching flag or config file setting.
+        GOCLACKER_DISPLAY and GOCLACKER_AUTOSAVE take true or false.
+    NO_COLOR
+        Do not color output or the prompt if set to anything.
+    GOCLACKER_SKIP_CONFIG
+        Comma-separated default config files not to read: system, user, home,
+        saved, or project.
+    Flags take precedence over environment variables, which take precedence
+    over the config file. Use the config operator to see where each setting
+    came from.
 `
 
 const (
@@ -61,19 +113,55 @@ const (
 
 // Command line flags
 var (
-	PrintVersion, StrictMode, Display, Color bool
-	ConfigPath, PromptFmt                    string
-	StackLimit                               int
+	PrintVersion, StrictMode, Display, Color, Autosave           bool
+	ConfigPath, PromptFmt, RightPromptFmt, SaveFile, SessionName string
+	StackLimit                                                   int
+	Seed                                                         int64
+	// Seeded is whether Seed was given.
+	Seeded bool
 )
 
-var DefConfigPaths = make([]string, 0)
-
 type colors struct {
 	out   []byte
 	err   []byte
 	reset []byte
 }
 
+// visibleLen returns the number of columns s takes up in a terminal, not
+// counting escape sequences.
+func visibleLen(s string) (n int) {
+	escape := false
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = !unicode.IsLetter(r)
+		case r == '\x1b':
+			escape = true
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// splitPrompt returns the lines of prompt above the line that input is typed
+// on, and that line.
+func splitPrompt(prompt string) (above string, line string) {
+	i := strings.LastIndexByte(prompt, '\n')
+	return prompt[:i+1], prompt[i+1:]
+}
+
+// rightAlign returns right moved to the right edge of a line that is width
+// columns wide and already has used columns filled, followed by a carriage
+// return. It returns an empty string if right does not fit.
+func rightAlign(right string, width int, used int) string {
+	n := visibleLen(right)
+	if right == "" || used+n >= width {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dG%s\r", width-n+1, right)
+}
+
 func GetStackOperator(interactive bool) *stack.StackOperator {
 	actions := stack.NewOrderedMap[string, *stack.Action]()
 	actions.Set("+", stack.Add)
@@ -87,16 +175,77 @@ func GetStackOperator(interactive bool) *stack.StackOperator {
 	actions.Set("ln", stack.Ln)
 	actions.Set("rad", stack.Radians)
 	actions.Set("deg", stack.Degrees)
+	actions.Set("deg-mode", stack.DegMode)
+	actions.Set("rad-mode", stack.RadMode)
+	actions.Set("grad-mode", stack.GradMode)
 	actions.Set("sin", stack.Sine)
 	actions.Set("cos", stack.Cosine)
 	actions.Set("tan", stack.Tangent)
 	actions.Set("asin", stack.Arcsine)
 	actions.Set("acos", stack.Arccosine)
 	actions.Set("atan", stack.Arctangent)
+	actions.Set("sinh", stack.Sinh)
+	actions.Set("cosh", stack.Cosh)
+	actions.Set("tanh", stack.Tanh)
+	actions.Set("asinh", stack.Asinh)
+	actions.Set("acosh", stack.Acosh)
+	actions.Set("atanh", stack.Atanh)
+	actions.Set("gamma", stack.Gamma)
+	actions.Set("lgamma", stack.LogGamma)
+	actions.Set("beta", stack.Beta)
+	actions.Set("erf", stack.Erf)
+	actions.Set("erfc", stack.Erfc)
+	actions.Set("j0", stack.BesselJ0)
+	actions.Set("j1", stack.BesselJ1)
+	actions.Set("jn", stack.BesselJn)
+	actions.Set("y0", stack.BesselY0)
+	actions.Set("y1", stack.BesselY1)
+	actions.Set("yn", stack.BesselYn)
 	actions.Set("floor", stack.Floor)
 	actions.Set("ceil", stack.Ceiling)
 	actions.Set("round", stack.Round)
+	actions.Set("to", stack.Convert)
+	actions.Set("units", stack.Units)
+	actions.Set("const", stack.Constants)
+	actions.Set("now", stack.Now)
+	actions.Set("dow", stack.DayOfWeek)
+	actions.Set("days-between", stack.DaysBetween)
+	actions.Set("solve", stack.Solve)
+	actions.Set("bisect", stack.Bisect)
+	actions.Set("secant", stack.Secant)
+	actions.Set("integrate", stack.Integrate)
+	actions.Set("deriv", stack.Deriv)
+	actions.Set("tol", stack.SetTolerance)
+	actions.Set("maxiter", stack.SetIterations)
+	actions.Set("dot", stack.Dot)
+	actions.Set("cross", stack.Cross)
+	actions.Set("det", stack.Det)
+	actions.Set("inv", stack.Inv)
+	actions.Set("transpose", stack.Transpose)
+	actions.Set("norm", stack.Norm)
+	actions.Set("polyval", stack.PolyVal)
+	actions.Set("polyadd", stack.PolyAdd)
+	actions.Set("polymul", stack.PolyMul)
+	actions.Set("polyder", stack.PolyDer)
+	actions.Set("polyint", stack.PolyInt)
+	actions.Set("roots", stack.Roots)
+	actions.Set("gcd", stack.GCD)
+	actions.Set("lcm", stack.LCM)
+	actions.Set("isprime", stack.IsPrime)
+	actions.Set("nextprime", stack.NextPrime)
+	actions.Set("factor", stack.Factor)
+	actions.Set("modpow", stack.ModPow)
+	actions.Set("modinv", stack.ModInv)
+	actions.Set("ncr", stack.Choose)
+	actions.Set("npr", stack.Permute)
 	actions.Set("rand", stack.Random)
+	actions.Set("seed", stack.Seed)
+	actions.Set("randint", stack.RandInt)
+	actions.Set("normal", stack.Normal)
+	actions.Set("exponential", stack.Exponential)
+	actions.Set("poisson", stack.Poisson)
+	actions.Set("choice", stack.Choice)
+	actions.Set("shuffle", stack.Shuffle)
 	actions.Set(".", stack.Display)
 	actions.Set(",", stack.Pop)
 	actions.Set("swap", stack.Swap)
@@ -104,11 +253,37 @@ func GetStackOperator(interactive bool) *stack.StackOperator {
 	actions.Set("rroll", stack.Rroll)
 	actions.Set("sum", stack.Sum)
 	actions.Set("avg", stack.Average)
+	actions.Set("linreg", stack.LinReg)
+	actions.Set("expreg", stack.ExpReg)
+	actions.Set("logreg", stack.LogReg)
+	actions.Set("pwrreg", stack.PowReg)
+	actions.Set("yhat", stack.YHat)
+	actions.Set("xhat", stack.XHat)
+	actions.Set("N", stack.StoreN)
+	actions.Set("I/YR", stack.StoreI)
+	actions.Set("PV", stack.StorePV)
+	actions.Set("PMT", stack.StorePMT)
+	actions.Set("FV", stack.StoreFV)
+	actions.Set("P/YR", stack.StorePY)
+	actions.Set("N?", stack.SolveN)
+	actions.Set("I/YR?", stack.SolveI)
+	actions.Set("PV?", stack.SolvePV)
+	actions.Set("PMT?", stack.SolvePMT)
+	actions.Set("FV?", stack.SolveFV)
+	actions.Set("BEG", stack.BeginMode)
+	actions.Set("END", stack.EndMode)
+	actions.Set("tvm", stack.ShowTVM)
+	actions.Set("amort", stack.Amortize)
+	actions.Set("npv", stack.NPV)
+	actions.Set("irr", stack.IRR)
 	actions.Set("stash", stack.Stash)
 	actions.Set("pull", stack.Pull)
 	actions.Set("clr", stack.Clear)
 	actions.Set("words", stack.Words)
 	actions.Set("help", stack.Help)
+	actions.Set("config", ShowConfig)
+	actions.Set("save", saveAction(true))
+	actions.Set("save-words", saveAction(false))
 	actions.Set("cls", stack.ClearScreen)
 	actions.Set("quit", stack.Quit)
 	actions.Set("Dclip", stack.Clip)
@@ -119,78 +294,11 @@ func GetStackOperator(interactive bool) *stack.StackOperator {
 	so.Words["randn"] = "rand * floor"
 	so.Words["sqrt"] = "0.5 ^"
 	so.Words["logb"] = "log swap log / -1 ^"
-	so.ValWords["pi"] = math.Pi
-	so.ValWords["e"] = math.E
+	so.ValWords["pi"] = stack.Number(math.Pi)
+	so.ValWords["e"] = stack.Number(math.E)
 	return so
 }
 
-// CheckDefConfigPaths checks if files exist in any of the default config file
-// paths and returns the path to the first one that exists. It returns an empty
-// string if none exist.
-func CheckDefConfigPaths() (path string) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "."
-	}
-	paths := []string{".goclacker"}
-	fromHome := []string{".goclacker", ".config/goclacker/goclacker.conf"}
-	for _, s := range fromHome {
-		paths = append(paths, fmt.Sprintf("%s%c%s", home, os.PathSeparator, s))
-	}
-	for _, s := range paths {
-		DefConfigPaths = append(DefConfigPaths, s)
-	}
-	for _, path = range DefConfigPaths {
-		if _, err = os.Open(path); err == nil {
-			return path
-		}
-	}
-	return ""
-}
-
-func GetConfigScanner(path string) (scanner *bufio.Scanner, msg string) {
-	if path == "" {
-		return nil, ""
-	}
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Sprintf("could not open config file : %v\n", err)
-	}
-	return bufio.NewScanner(f), fmt.Sprintf("parsing config file... %s\n", path)
-}
-
-func ReadPromptLine(scanner *bufio.Scanner) (promptFmt string, msg string) {
-	if scanner == nil {
-		return DefPrompt, ""
-	}
-	scanner.Scan()
-	if err := scanner.Err(); err != nil {
-		return DefPrompt, fmt.Sprintf("could not read prompt line : %v\n", err)
-	}
-	promptFmt = scanner.Text()
-	if promptFmt == "" {
-		return DefPrompt, ""
-	}
-	promptFmt = strings.TrimPrefix(promptFmt, `"`)
-	promptFmt = strings.TrimSuffix(promptFmt, `"`)
-	return promptFmt, ""
-}
-
-func ReadProgLines(scanner *bufio.Scanner, so *stack.StackOperator) (msg string) {
-	if scanner == nil {
-		return
-	}
-	for scanner.Scan() {
-		if err := so.ParseInput(strings.TrimSpace(scanner.Text())); err != nil {
-			fmt.Fprint(os.Stderr, err)
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Sprintf("could not read config file : %v\n", err)
-	}
-	return "sucessfully parsed config file\n"
-}
-
 func ExecutePrograms(so *stack.StackOperator, programs []string) (eof error) {
 	for _, s := range programs {
 		if err := so.ParseInput(s); err != nil {
@@ -207,33 +315,57 @@ func run() (err error) {
 		return io.EOF
 	}
 
-	so := GetStackOperator(len(flag.Args()) == 0)
-	if ConfigPath == "\x00" {
-		ConfigPath = CheckDefConfigPaths()
-	}
-	scanner, msg := GetConfigScanner(ConfigPath)
+	files := ConfigFiles()
+	conf, msg := ReadConfig(files)
 	fmt.Fprint(os.Stderr, msg)
-
-	s, msg := ReadPromptLine(scanner)
+	fmt.Fprint(os.Stderr, ApplyOptions(conf))
+	loadedConfig = conf
+	if SaveFile == "" {
+		SaveFile = configLayers()["saved"]
+	}
 	if PromptFmt == "\x00" {
-		PromptFmt = s
+		PromptFmt = DefPrompt
 	}
-	fmt.Fprint(os.Stderr, msg)
 
-	msg = ReadProgLines(scanner, so)
-	fmt.Fprint(os.Stderr, msg)
+	so := GetStackOperator(len(flag.Args()) == 0)
+	if Seeded {
+		so.Rand.Seed(Seed)
+	}
+	fmt.Fprint(os.Stderr, conf.Load(so))
+	loadBaseline(files)
+	so.SessionDir = sessionDir()
+	if SessionName != "" {
+		// A session that does not exist yet is made when it is first saved.
+		if err = so.LoadSession(SessionName); err != nil && !errors.Is(err, stack.ErrNoSession) {
+			return fmt.Errorf("could not load session %s : %v", SessionName, err)
+		}
+		so.Session = SessionName
+	}
 
 	if !so.Interactive {
 		return ExecutePrograms(so, flag.Args())
 	}
 
+	so.Color = Color
 	if err = so.MakePromptFunc(PromptFmt, FmtChar); err != nil {
 		return err
 	}
+	if RightPromptFmt != "" {
+		if err = so.MakeRightPromptFunc(RightPromptFmt, FmtChar); err != nil {
+			return err
+		}
+	}
 
 	fmt.Fprintf(os.Stderr, "goclacker %s\n", Version)
 	err = interactive(so, Color)
 	fmt.Println()
+	if err == io.EOF && Autosave {
+		msg, saveErr := WriteSave(so, SaveFile, true)
+		fmt.Fprint(os.Stderr, msg)
+		if saveErr != nil {
+			fmt.Fprint(os.Stderr, saveErr)
+		}
+	}
 	return err
 }
 
@@ -259,8 +391,19 @@ func main() {
 	flag.StringVar(&PromptFmt, "p", "\x00", "")
 	flag.StringVar(&PromptFmt, "prompt", "\x00", "")
 
+	flag.StringVar(&RightPromptFmt, "P", "", "")
+	flag.StringVar(&RightPromptFmt, "right-prompt", "", "")
+
+	flag.Int64Var(&Seed, "seed", 0, "")
+
+	flag.StringVar(&SaveFile, "save-file", "", "")
+	flag.BoolVar(&Autosave, "autosave", false, "")
+
+	flag.StringVar(&SessionName, "session", "", "")
+
 	flag.Usage = func() { fmt.Print(strings.Replace(Usage, "<version>", Version, 1)) }
 	flag.Parse()
+	Seeded = lookupOption("seed").givenFlag() != ""
 
 	Display = !Display
 	Color = !Color
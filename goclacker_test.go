@@ -5,12 +5,18 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jtompkin/goclacker/internal/stack"
 )
 
 func prompt(t *testing.T, format string, expected string) {
 	so := GetStackOperator(false)
-	so.Stack.Stash = 12
+	so.Stack.Stash = stack.Number(12)
 	so.MakePromptFunc(format, '&')
 	if s := so.Prompt(); s != expected {
 		t.Fatalf(`format = "%s" : expected = "%s" : got  = "%s"`, format, expected, s)
@@ -30,10 +36,105 @@ func TestPrompts(t *testing.T) {
 		fmt.Sprintf(" %c > ", FmtChar): fmt.Sprintf(" %c > ", FmtChar),
 		fmt.Sprintf("%c%c%c", FmtChar, FmtChar, FmtChar):                     fmt.Sprintf("%c%c%c", FmtChar, FmtChar, FmtChar),
 		fmt.Sprintf("%cl%cc&3t%cs%c10t", FmtChar, FmtChar, FmtChar, FmtChar): "80N N N12N N N N N N N N N N",
+		fmt.Sprintf("%ca > ", FmtChar):                                       "RAD > ",
+		fmt.Sprintf("%c1t|%c2t", FmtChar, FmtChar):                           "N|N N",
+		fmt.Sprintf("%c3.2t", FmtChar):                                       "N N N",
+		fmt.Sprintf("%c4c|%c4l", FmtChar, FmtChar):                           "   0|   8",
+		fmt.Sprintf("%c.1s", FmtChar):                                        "1e+01",
+		fmt.Sprintf(`\%cc \\ %c\n> `, FmtChar, FmtChar):                      "&c \\ &\n> ",
+		fmt.Sprintf("%c{red}%cc%c{reset}", FmtChar, FmtChar, FmtChar):        "0",
+		"&?{&s}{[&s] }> ":      "[12] > ",
+		"&?{&c=&l}{! }{ok }>":  "ok >",
+		"&?!{&c}{empty}{full}": "empty",
+		"&?{&e}{x}":            "",
+		`&?{&c=0}{\}}`:         "}",
+		"&v{pi} &.3v{e}":       "3.141592653589793 2.72",
+		"&v{math.phi}":         "1.618033988749895",
+		"[&v{nope}&r]":         "[]",
+		"&w words, &u":         "4 words, 0s",
+		"[&?{&n}{&n}{none}]":   "[none]",
 	}
 	for format, expected := range formats {
 		prompt(t, format, expected)
 	}
+	colored := map[string]string{
+		fmt.Sprintf("%c{red}%cc%c{reset}", FmtChar, FmtChar, FmtChar): "\x1b[31m0\x1b[0m",
+		fmt.Sprintf("%c{bg:bright-blue}%c{bold}", FmtChar, FmtChar):   "\x1b[104m\x1b[1m",
+		fmt.Sprintf("%c{208}", FmtChar):                               "\x1b[38;5;208m",
+		fmt.Sprintf("%c{bg:#ff8800}", FmtChar):                        "\x1b[48;2;255;136;0m",
+	}
+	for format, expected := range colored {
+		so := GetStackOperator(false)
+		so.Color = true
+		so.MakePromptFunc(format, FmtChar)
+		if s := so.Prompt(); s != expected {
+			t.Fatalf(`format = "%s" : expected = %q : got = %q`, format, expected, s)
+		}
+	}
+	so := GetStackOperator(false)
+	so.Record("2 3 +", so.ParseInput("2 3 +"))
+	so.Record("1 0 /", so.ParseInput("1 0 /"))
+	so.MakePromptFunc("&r &?{&e}{error}", FmtChar)
+	if s := so.Prompt(); s != "5 error" {
+		t.Fatalf(`format = "&r &?{&e}{error}" : expected = "5 error" : got = %q`, s)
+	}
+	bad := map[string]int{
+		fmt.Sprintf(" %cq > ", FmtChar):       3,
+		fmt.Sprintf("%c8.", FmtChar):          4,
+		fmt.Sprintf("%c3 > ", FmtChar):        3,
+		fmt.Sprintf("%c.t", FmtChar):          3,
+		`> \`:                                 3,
+		`\w > `:                               1,
+		fmt.Sprintf("%c{purple}", FmtChar):    3,
+		fmt.Sprintf("%c{purpel}", FmtChar):    3,
+		fmt.Sprintf("%c{fg:purple}", FmtChar): 3,
+		"&v > ":                               3,
+		"&v{}":                                4,
+		fmt.Sprintf("%c{red", FmtChar):        2,
+		fmt.Sprintf("%c{fg:bold}", FmtChar):   3,
+		"&?{&c":                               6,
+		"&?x":                                 3,
+		"&?{&c}":                              7,
+	}
+	for format, column := range bad {
+		err := GetStackOperator(false).MakePromptFunc(format, FmtChar)
+		if pe, ok := err.(*stack.PromptError); !ok || pe.Column != column {
+			t.Fatalf(`format = "%s" : expected error at column %d : got = %v`, format, column, err)
+		}
+	}
+}
+
+func TestSessionPrompt(t *testing.T) {
+	so := GetStackOperator(false)
+	so.SessionDir = t.TempDir()
+	so.MakePromptFunc("(&n) > ", '&')
+	if s := so.Prompt(); s != "() > " {
+		t.Fatalf(`expected "() > " with no session : got = %q`, s)
+	}
+	so.ParseInput("session save work")
+	if s := so.Prompt(); s != "(work) > " {
+		t.Fatalf(`expected "(work) > " : got = %q`, s)
+	}
+}
+
+func TestPromptLines(t *testing.T) {
+	so := GetStackOperator(false)
+	so.MakePromptFunc(`&2t\n&{red}&c > `, FmtChar)
+	so.Color = true
+	above, line := splitPrompt(so.Prompt())
+	if above != "N N\n" || line != "\x1b[31m0 > " {
+		t.Fatalf(`expected = "N N\n", "\x1b[31m0 > " : got = %q, %q`, above, line)
+	}
+	if n := visibleLen(line); n != 4 {
+		t.Fatalf(`line = %q : expected length 4 : got = %d`, line, n)
+	}
+	so.MakeRightPromptFunc("&a", FmtChar)
+	if s := rightAlign(so.RightPrompt(), 20, 4); s != "\x1b[18GRAD\r" {
+		t.Fatalf(`expected = "\x1b[18GRAD\r" : got = %q`, s)
+	}
+	if s := rightAlign(so.RightPrompt(), 7, 4); s != "" {
+		t.Fatalf(`expected right prompt to not fit : got = %q`, s)
+	}
 }
 
 func prog(t *testing.T, program string, params progParams) {
@@ -89,8 +190,541 @@ func TestPrograms(t *testing.T) {
 		"help":         {"", false, true},
 		"words":        {"", false, true},
 		"  3 4 * 4455 -    23         + 4 4332     ": {"-4420 4 4332\n", false, false},
+
+		// curve fitting
+		"1 2 2 4 3 6 linreg":                    {"2 0 1\n", false, false},
+		"1 2 2 4 3 6 linreg , , 5 yhat":         {"2 10\n", false, false},
+		"1 2 2 4 3 6 linreg , , 10 xhat":        {"2 5\n", false, false},
+		"1 2 2 4 4 8 pwrreg clr 3 yhat 6 round": {"6\n", false, false},
+		"0 1 1 2 2 4 expreg clr 3 yhat 6 round": {"8\n", false, false},
+		"1 2 2 2 3 2 linreg":                    {"0 2 0\n", false, false},
+		"1 0.1 2 0.1 3 0.1 linreg , ,":          {"0\n", false, false},
+		"0.1 1 0.1 2 0.1 3 linreg":              {"", true, false},
+		"1 2 3 linreg":                          {"", true, false},
+		"1 2 1 4 linreg":                        {"", true, false},
+		"5 yhat":                                {"", true, false},
+
+		// time value of money
+		"360 N 6 I/YR 100000 PV 0 FV PMT? 2 round":          {"-599.55\n", false, false},
+		"6 I/YR 100000 PV -599.55 PMT 0 FV N? 0 round":      {"360\n", false, false},
+		"360 N 100000 PV -599.55 PMT 0 FV I/YR? 2 round":    {"6\n", false, false},
+		"360 N 6 I/YR -599.55 PMT 0 FV PV? 0 round":         {"100000\n", false, false},
+		"10 N 0 I/YR 0 PV -100 PMT FV?":                     {"1000\n", false, false},
+		"BEG 12 N 12 I/YR 1 P/YR -100 PV 0 PMT FV? 2 round": {"389.6\n", false, false},
+		"-100 50 60 10 npv 2 round":                         {"-4.96\n", false, false},
+		"-100 60 60 irr 2 round":                            {"13.07\n", false, false},
+		"0 P/YR":                                            {"", true, false},
+		"1 2 3 irr":                                         {"", true, false},
+		"1e12 N amort":                                      {"", true, false},
+		"2.5 N amort":                                       {"", true, false},
+
+		// units
+		"5_km 3_h /":           {"1.6666666666666667_km/h\n", false, false},
+		"5_km _mi to 4 round":  {"3.1069_mi\n", false, false},
+		"5_km 300_m +":         {"5.3_km\n", false, false},
+		"5_km 1_m /":           {"5000\n", false, false},
+		"2_m 2 ^":              {"4_m^2\n", false, false},
+		"3_m 2_m * 10_s /":     {"0.6_m^2/s\n", false, false},
+		"=u furlong 201.168_m": {"defined unit furlong = 201.168_m\n", false, false},
+		"5_km 3_s +":           {"", true, false},
+		"5_km _s to":           {"", true, false},
+		"1_s sin":              {"", true, false},
+		"=u m 2":               {"", true, false},
+
+		// constants
+		"phys.c":                          {"2.99792458e+08_m/s\n", false, false},
+		"math.phi 3 round":                {"1.618\n", false, false},
+		"phys.c 2_s * _km to":             {"599584.916_km\n", false, false},
+		"phys.k_B 300_K * _eV to 4 round": {"0.0259_eV\n", false, false},
+		"phys.nope":                       {"", false, false},
+		"const":                           {"", false, true},
+
+		// dates and times
+		"2026-10-16 dow":                           {"5\n", false, false},
+		"2026-10-16 3d +":                          {"2026-10-19\n", false, false},
+		"2026-10-16T09:00 90m +":                   {"2026-10-16T10:30\n", false, false},
+		"2026-10-16 2026-10-01 -":                  {"15d\n", false, false},
+		"2026-10-01 2026-10-16 days-between":       {"15\n", false, false},
+		"2h30m 2 *":                                {"5h\n", false, false},
+		"2h30m 30m /":                              {"5\n", false, false},
+		"3d 1_h +":                                 {"3d1h\n", false, false},
+		"2026-10-16T14:30:00Z America/New_York to": {"2026-10-16T10:30:00-04:00\n", false, false},
+		"2026-10-16 1 +":                           {"", true, false},
+		"5 dow":                                    {"", true, false},
+
+		// number theory
+		"12 18 gcd":                  {"6\n", false, false},
+		"-12 18 gcd":                 {"6\n", false, false},
+		"4 6 lcm":                    {"12\n", false, false},
+		"97 isprime":                 {"1\n", false, false},
+		"91 isprime":                 {"0\n", false, false},
+		"1 isprime":                  {"0\n", false, false},
+		"100 nextprime":              {"101\n", false, false},
+		"360 factor":                 {"2 2 2 3 3 5\n", false, false},
+		"1 factor":                   {"", true, false},
+		"8051 factor":                {"83 97\n", false, false},
+		"1000000016000000063 factor": {"1000000007 1000000009\n", false, false},
+		"3000000000000000046000000000000000111 factor": {"", true, false},
+		"4 13 497 modpow":          {"445\n", false, false},
+		"3 11 modinv":              {"4\n", false, false},
+		"2 4 modinv":               {"", true, false},
+		"5 2 ncr":                  {"10\n", false, false},
+		"5 2 npr":                  {"20\n", false, false},
+		"2 5 ncr":                  {"0\n", false, false},
+		"25 !":                     {"15511210043330985984000000\n", false, false},
+		"2.5 !":                    {"3.323350970447843\n", false, false},
+		"-1 !":                     {"", true, false},
+		"20 ! 21 * 21 ! -":         {"0\n", false, false},
+		"12345678901234567890 1 +": {"12345678901234567891\n", false, false},
+		"4 ! 2 /":                  {"12\n", false, false},
+		"4 ! 5 /":                  {"4.8\n", false, false},
+
+		// special functions
+		"5 gamma":                         {"24\n", false, false},
+		"0 gamma":                         {"", true, false},
+		"-2 gamma":                        {"", true, false},
+		"2 3 beta 12 * 6 round":           {"1\n", false, false},
+		"0 erf":                           {"0\n", false, false},
+		"0 erfc":                          {"1\n", false, false},
+		"0 j0":                            {"1\n", false, false},
+		"0 j1":                            {"0\n", false, false},
+		"0 0 jn":                          {"1\n", false, false},
+		"2.5 0.5 jn":                      {"", true, false},
+		"0 y0":                            {"", true, false},
+		"-2 1 yn":                         {"", true, false},
+		"0 sinh":                          {"0\n", false, false},
+		"0 cosh":                          {"1\n", false, false},
+		"0 tanh":                          {"0\n", false, false},
+		"0 asinh":                         {"0\n", false, false},
+		"1 acosh":                         {"0\n", false, false},
+		"0.5 acosh":                       {"", true, false},
+		"1 atanh":                         {"", true, false},
+		"0.5 ! 2 ^ 4 * math.pi / 6 round": {"1\n", false, false},
+		"-1.5 !":                          {"-3.5449077018110318\n", false, false},
+
+		// angle mode
+		"deg-mode 90 sin":         {"1\n", false, false},
+		"deg-mode 180 cos":        {"-1\n", false, false},
+		"deg-mode 1 atan":         {"45\n", false, false},
+		"deg-mode 0.5 acos":       {"60\n", false, false},
+		"deg-mode 90 tan":         {"", true, false},
+		"deg-mode 360 sin":        {"0\n", false, false},
+		"deg-mode 180 tan":        {"0\n", false, false},
+		"deg-mode -180 sin":       {"0\n", false, false},
+		"grad-mode 200 cos":       {"-1\n", false, false},
+		"grad-mode 1 asin":        {"100\n", false, false},
+		"deg-mode rad-mode 0 cos": {"1\n", false, false},
+		"deg-mode":                {"angle mode: degrees\n", false, false},
+
+		// root finding
+		"'cos 1 solve":                            {"1.5707963267948966\n", false, false},
+		"'sin 3 solve 6 round":                    {"3.141593\n", false, false},
+		"'cos 1 2 bisect 6 round":                 {"1.570796\n", false, false},
+		"'cos 2 3 bisect":                         {"", true, false},
+		"'cos 1 2 secant":                         {"1.5707963267948966\n", false, false},
+		"'exp 1 2 secant":                         {"", true, false},
+		"= f 'f 1 solve":                          {"", true, false},
+		"= g 1 + = f 'g 1 solve = g f 'f 1 solve": {"", true, false},
+		"'exp 1 solve":                            {"", true, false},
+		"1 2 solve":                               {"", true, false},
+		"'cos":                                    {"'cos\n", false, false},
+		"1e-3 tol 'cos 1 2 bisect 2 round":        {"1.57\n", false, false},
+		"0 tol":                                   {"", true, false},
+		"2.5 maxiter":                             {"", true, false},
+
+		// integration and differentiation
+		"'sin 0 math.pi integrate":                {"2\n", false, false},
+		"'cos 0 1 integrate 1 sin - 10 round":     {"0\n", false, false},
+		"'sin 1 0 integrate 1 cos 1 - - 10 round": {"0\n", false, false},
+		"'ln 0 1 integrate":                       {"", true, false},
+		"'floor 0 10.5 integrate 6 round":         {"50\n", false, false},
+		"10 maxiter 'sqrt 0 1 integrate":          {"", true, false},
+		"'sin 0 deriv 8 round":                    {"1\n", false, false},
+		"'ln 2 deriv 8 round":                     {"0.5\n", false, false},
+		"1 2 deriv":                               {"", true, false},
+
+		// vectors and matrices
+		"[1 2 3] [4 5 6] +":             {"[5 7 9]\n", false, false},
+		"[1 2 3] 2 *":                   {"[2 4 6]\n", false, false},
+		"2 [1 2 4] /":                   {"[2 1 0.5]\n", false, false},
+		"[1 2] [1 2 3] +":               {"", true, false},
+		"[1 2 3] [4 5 6] dot":           {"32\n", false, false},
+		"[1 0 0] [0 1 0] cross":         {"[0 0 1]\n", false, false},
+		"[1 0] [0 1] cross":             {"", true, false},
+		"[[1 2][3 4]] det":              {"-2\n", false, false},
+		"[[1 2][2 4]] det":              {"0\n", false, false},
+		"[[2 0] [0 4]] inv":             {"[[0.5 0] [0 0.25]]\n", false, false},
+		"[[1 2][2 4]] inv":              {"", true, false},
+		"[[1 2][3 4]] transpose":        {"[[1 3] [2 4]]\n", false, false},
+		"[1 2] transpose":               {"[[1] [2]]\n", false, false},
+		"[3 4] norm":                    {"5\n", false, false},
+		"[[2 1][1 3]] [3 5] solve":      {"[0.8 1.4]\n", false, false},
+		"[[1 2][3 4]] [[5 6][7 8]] dot": {"[[19 22] [43 50]]\n", false, false},
+		"[[1 2][3 4]] [1 1] dot":        {"[3 7]\n", false, false},
+		"[[1 2 3]] det":                 {"", true, false},
+		"[1 2":                          {"", true, false},
+		"[1 2]]":                        {"", true, false},
+		"[[1 2][3]]":                    {"", true, false},
+		"[1 x]":                         {"", true, false},
+		"[]":                            {"", true, false},
+
+		// polynomials and complex numbers
+		"[1 -3 2] roots": {"1 2\n", false, false},
+		"[1 0 1] roots":  {"(0-1i) (0+1i)\n", false, false},
+		"[2 -4] roots":   {"2\n", false, false},
+		"[1 0 0] roots":  {"0 0\n", false, false},
+		"[1 -6 11 -6] roots 3 round rroll 3 round rroll 3 round rroll": {"1 2 3\n", false, false},
+		"[5] roots":             {"", true, false},
+		"[1 -3 2] 3 polyval":    {"2\n", false, false},
+		"[1 0 1] 1i polyval":    {"0\n", false, false},
+		"[1 2] [1 3] polyadd":   {"[2 5]\n", false, false},
+		"[1 -1] [-1 1] polyadd": {"[0]\n", false, false},
+		"[1 2] [1 3] polymul":   {"[1 5 6]\n", false, false},
+		"[3 2 1] polyder":       {"[6 2]\n", false, false},
+		"[3 2 1] polyint":       {"[1 1 1 0]\n", false, false},
+		"1 polyder":             {"", true, false},
+		"1+2i 1-2i *":           {"5\n", false, false},
+		"(1+2i) 2 *":            {"(2+4i)\n", false, false},
+		"2i 2 ^":                {"-4\n", false, false},
+		"1+2i 2 %":              {"", true, false},
+
+		// uncertainty
+		"9.81+-0.02":      {"9.81 ± 0.02\n", false, false},
+		"9.81±0.02 2 *":   {"19.62 ± 0.04\n", false, false},
+		"3+-0.3 4+-0.4 +": {"7 ± 0.5\n", false, false},
+		"10 1+-0.1 -":     {"9 ± 0.1\n", false, false},
+		"2+-0.1 3 ^":      {"8 ± 1.2000000000000002\n", false, false},
+		"0+-0.1 ln":       {"", true, false},
+		"0+-0.1 sin":      {"0 ± 0.1\n", false, false},
+		"1+-0.1 ln":       {"0 ± 0.1\n", false, false},
+		"1.5+-0.1 floor":  {"1 ± 0.1\n", false, false},
+		"1+--0.1":         {"", false, false},
+
+		// intervals
+		"[1,2] [3,4] +":         {"[4,6]\n", false, false},
+		"[1,2] [3,4] -":         {"[-3,-1]\n", false, false},
+		"[1,2] [-3,4] *":        {"[-6,8]\n", false, false},
+		"[1,2] [-1,1] /":        {"", true, false},
+		"[-2,3] 2 ^":            {"[0,9]\n", false, false},
+		"[5,6] 4 %":             {"[1,2]\n", false, false},
+		"[2,1]":                 {"", true, false},
+		"[1.2,2.7] floor":       {"[1,2]\n", false, false},
+		"[-1,1] cosh":           {"[1,1.5430806348152446]\n", false, false},
+		"deg-mode [0,180] sin":  {"[0,1]\n", false, false},
+		"deg-mode [80,100] tan": {"", true, false},
+		"[0.1,0.1] [0.2,0.2] +": {"[0.29999999999999993,0.30000000000000004]\n", false, false},
+		"[0.1,0.1]":             {"[0.09999999999999999,0.1]\n", false, false},
+		"[0.5,2]":               {"[0.5,2]\n", false, false},
+
+		// random
+		"1 seed rand":        {"0.6046602879796196\n", false, false},
+		"1 seed 1 6 randint": {"3\n", false, false},
+		"2 2 randint":        {"2\n", false, false},
+		"6 1 randint":        {"", true, false},
+		"1.5 seed":           {"", true, false},
+		"10 0 normal":        {"10\n", false, false},
+		"0 exponential":      {"", true, false},
+		"-1 poisson":         {"", true, false},
+		"nan poisson":        {"", true, false},
+		"0 poisson":          {"0\n", false, false},
+		"5 choice":           {"5\n", false, false},
+		"7 shuffle":          {"7\n", false, false},
 	}
 	for program, params := range programs {
 		prog(t, program, params)
 	}
 }
+
+func TestConfig(t *testing.T) {
+	Display = true
+	dir := t.TempDir()
+	configs := map[string]string{
+		"legacy":         "------> \"\n= sq 2 ^\n3 sq\n",
+		"legacy-section": "[&c/&l]\n= sq 2 ^\n3 sq\n",
+		"legacy-comment": "# &c > \n= sq 2 ^\n3 sq\n",
+		"marked":         "# goclacker config\n[words]\nsq = 2 ^\n[programs]\n3 sq\n",
+		"structured": "# comment\nlimit = 4\nprompt = \"> \"\n\n[words]\nsq = 2 ^\n\n" +
+			"[values]\nthree = 3\n\n[programs]\nthree sq\n",
+	}
+	for name, contents := range configs {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		StackLimit, PromptFmt = DefLimit, "\x00"
+		conf, _ := ReadConfig([]configFile{{path, "test"}})
+		if msg := ApplyOptions(conf); msg != "" {
+			t.Fatalf(`config = %q : unexpected message %q`, name, msg)
+		}
+		so := GetStackOperator(false)
+		conf.Load(so)
+		if s := so.Stack.Display(); s != "9\n" {
+			t.Fatalf(`config = %q : expected stack "9\n" : got = %q`, name, s)
+		}
+		if name == "structured" && (StackLimit != 4 || PromptFmt != "> ") {
+			t.Fatalf(`config = %q : expected limit 4 and prompt "> " : got = %d, %q`, name, StackLimit, PromptFmt)
+		}
+		legacyPrompts := map[string]string{"legacy": "------> ", "legacy-section": "[&c/&l]", "legacy-comment": "# &c > "}
+		if want, found := legacyPrompts[name]; found && PromptFmt != want {
+			t.Fatalf(`config = %q : expected prompt %q : got = %q`, name, want, PromptFmt)
+		}
+	}
+	t.Setenv("GOCLACKER_LIMIT", "6")
+	t.Setenv("NO_COLOR", "1")
+	StackLimit, Color = DefLimit, true
+	structured := filepath.Join(dir, "structured")
+	conf, _ := ReadConfig([]configFile{{structured, "test"}})
+	ApplyOptions(conf)
+	if StackLimit != 6 || Color {
+		t.Fatalf("expected environment to override config file : got limit = %d, color = %v", StackLimit, Color)
+	}
+	want := "limit = 6 (environment variable GOCLACKER_LIMIT)\n"
+	if s, _ := ShowConfig.Call(GetStackOperator(false)); !strings.Contains(s, want) {
+		t.Fatalf("expected config to contain %q : got = %q", want, s)
+	}
+	want = fmt.Sprintf("prompt = \"> \" (config file %s)\n", structured)
+	if s, _ := ShowConfig.Call(GetStackOperator(false)); !strings.Contains(s, want) {
+		t.Fatalf("expected config to contain %q : got = %q", want, s)
+	}
+	StackLimit, PromptFmt, Color = DefLimit, "\x00", false
+	if _, msg := parseStructured([]string{"limit = 4", "bogus = 1", "[nope]"}); msg == "" {
+		t.Fatal("expected messages for unknown setting and section")
+	}
+}
+
+func TestConfigLayers(t *testing.T) {
+	Display = true
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("GOCLACKER_SKIP_CONFIG", "system, project")
+	files := map[string]string{
+		"xdg/goclacker/config": "limit = 4\n[words]\nsq = 2 ^\n[values]\nthree = 3\n",
+		".goclacker":           "include = base.conf\n[words]\nsq = 3 ^\n",
+		"base.conf":            "include = ~/.goclacker\nlimit = 5\nprompt = \"> \"\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	StackLimit, PromptFmt, ConfigPath = DefLimit, "\x00", "\x00"
+	conf, msg := ReadConfig(ConfigFiles())
+	if len(conf.Files) != 3 {
+		t.Fatalf("expected 3 config files : got = %v", conf.Files)
+	}
+	if !strings.Contains(msg, "includes itself") {
+		t.Fatalf("expected message about include cycle : got = %q", msg)
+	}
+	ApplyOptions(conf)
+	if StackLimit != 5 || PromptFmt != "> " {
+		t.Fatalf(`expected limit 5 and prompt "> " : got = %d, %q`, StackLimit, PromptFmt)
+	}
+	so := GetStackOperator(false)
+	conf.Load(so)
+	so.ParseInput("three sq")
+	if s := so.Stack.Display(); s != "27\n" {
+		t.Fatalf(`expected later layers to redefine words : expected stack "27\n" : got = %q`, s)
+	}
+	t.Setenv("GOCLACKER_SKIP_CONFIG", "system,user,home,project")
+	if files := ConfigFiles(); len(files) != 0 {
+		t.Fatalf("expected every layer to be skipped : got = %v", files)
+	}
+	StackLimit, PromptFmt = DefLimit, "\x00"
+}
+
+func TestSave(t *testing.T) {
+	Display = true
+	PromptFmt, RightPromptFmt = "\x00", ""
+	dir := t.TempDir()
+	user, path := filepath.Join(dir, "config"), filepath.Join(dir, "saved")
+	os.WriteFile(user, []byte("# goclacker config\nprompt = \"&a > \"\n\n[words]\nsq = 2 ^\ncube = 3 ^\n\n[values]\nv = [1 2 3]\n"), 0o644)
+	os.WriteFile(path, []byte("# goclacker config\nright-prompt = \"&u\"\n"), 0o644)
+	files := []configFile{{user, "test"}, {path, "test"}}
+	SaveFile = path
+	conf, _ := ReadConfig(files)
+	ApplyOptions(conf)
+	loadBaseline(files)
+	defer func() { baseline.so, baseline.settings = nil, nil }()
+	so := GetStackOperator(false)
+	conf.Load(so)
+	for _, p := range []string{"= dbl 2 *", "== g 9.81", "== u 1+-0.5", "=u furlong 201.168_m",
+		"== f 2_furlong", "deg-mode", "[4 5] stash", "360 N", "BEG", "= cube"} {
+		if err := so.ParseInput(p); err != nil {
+			t.Fatalf("program = %q : unexpected error %q", p, err)
+		}
+	}
+	if err := so.ParseInput("save"); err != nil {
+		t.Fatalf("unexpected error saving : %q", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if s := string(saved); strings.Contains(s, "sq") || strings.Contains(s, "v =") || strings.Contains(s, "prompt = \"&a") {
+		t.Fatalf("expected save file to not copy the user config : got = %q", s)
+	}
+	if s := string(saved); !strings.Contains(s, "\ncube =\n") {
+		t.Fatalf("expected save file to delete cube : got = %q", s)
+	}
+	PromptFmt, RightPromptFmt = "\x00", ""
+	conf, msg := ReadConfig(files)
+	if msg2 := ApplyOptions(conf); strings.Contains(msg, "config line") || msg2 != "" {
+		t.Fatalf("unexpected message reading save file : %q", msg+msg2)
+	}
+	if PromptFmt != "&a > " || RightPromptFmt != "&u" {
+		t.Fatalf(`expected prompts "&a > " and "&u" : got = %q, %q`, PromptFmt, RightPromptFmt)
+	}
+	loaded := GetStackOperator(false)
+	conf.Load(loaded)
+	loaded.ParseInput("g dbl u f pull 90 sin 3 sq v")
+	if s, want := loaded.Stack.Display(), "19.62 1 ± 0.5 2_furlong [4 5] 1 9 [1 2 3]\n"; s != want {
+		t.Fatalf("expected stack %q : got = %q", want, s)
+	}
+	if _, found := loaded.Words["cube"]; found {
+		t.Fatalf("expected cube to stay deleted : got = %v", loaded.Words)
+	}
+	if loaded.TVM.N != 360 || !loaded.TVM.Begin {
+		t.Fatalf("expected TVM registers to be restored : got = %v", loaded.TVM)
+	}
+	t.Setenv("GOCLACKER_PROMPT", "env > ")
+	ApplyOptions(conf)
+	if s := Save(loaded, true); strings.Contains(s, "env > ") {
+		t.Fatalf("expected prompt from the environment to not be saved : got = %q", s)
+	}
+	baseline.so, baseline.settings = nil, nil
+	if s := Save(GetStackOperator(false), false); strings.Contains(s, "[") {
+		t.Fatalf("expected no sections when nothing was defined : got = %q", s)
+	}
+	PromptFmt, RightPromptFmt, SaveFile = "\x00", "", ""
+}
+
+func TestSession(t *testing.T) {
+	Display = true
+	so := GetStackOperator(false)
+	so.SessionDir = t.TempDir()
+	lines := []string{"=u furlong 201.168_m", "== f 2_furlong", "= dbl 2 *", "grad-mode", "3 stash",
+		"1+-0.5 [1,2] (3+4i) f", "session save work"}
+	for _, line := range lines {
+		so.Record(line, so.ParseInput(line))
+	}
+	if so.Session != "work" {
+		t.Fatalf(`expected current session "work" : got = %q`, so.Session)
+	}
+	so.Record("dbl", so.ParseInput("dbl"))
+	loaded := GetStackOperator(false)
+	loaded.SessionDir = so.SessionDir
+	if err := loaded.ParseInput("session load work"); err != nil {
+		t.Fatalf("unexpected error loading session : %q", err)
+	}
+	if s, want := loaded.Stack.Display(), "1 ± 0.5 [1,2] (3+4i) 4_furlong\n"; s != want {
+		t.Fatalf("expected stack %q : got = %q", want, s)
+	}
+	if len(loaded.History) != len(lines)+1 || loaded.Angle != stack.GradianMode {
+		t.Fatalf("expected history and angle mode to be restored : got = %q, %v", loaded.History, loaded.Angle)
+	}
+	loaded.ParseInput("pull 1 f dbl")
+	if s, want := loaded.Stack.Display(), "1 ± 0.5 [1,2] (3+4i) 4_furlong 3 1 4_furlong\n"; s != want {
+		t.Fatalf("expected stack %q : got = %q", want, s)
+	}
+	for _, bad := range []string{"session", "session load nope", "session save ../x", "session frob"} {
+		if err := loaded.ParseInput(bad); err == nil {
+			t.Fatalf("program = %q : expected error", bad)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(so.SessionDir, "new.json"), []byte(`{"version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadSession("new"); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("expected error about newer version : got = %v", err)
+	}
+}
+
+func TestDaylightSaving(t *testing.T) {
+	Display = true
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	local := time.Local
+	time.Local = loc
+	defer func() { time.Local = local }()
+	programs := map[string]progParams{
+		"2026-03-07 2d +":                                {"2026-03-09\n", false, false},
+		"2026-03-07T12:00 1w1d6h +":                      {"2026-03-15T18:00\n", false, false},
+		"2026-03-09 1d -":                                {"2026-03-08\n", false, false},
+		"2026-03-01 2026-03-15 days-between":             {"14\n", false, false},
+		"2026-03-07T12:00 2026-03-09T00:00 days-between": {"1.5\n", false, false},
+	}
+	for program, params := range programs {
+		prog(t, program, params)
+	}
+}
+
+func TestUnitNames(t *testing.T) {
+	Display = true
+	// Units must be written with a _, so a unit on its own does nothing to the
+	// stack, or is an error in strict mode.
+	stacks := map[string]string{
+		"2 2 + m":     "4\n",
+		"3 m s":       "3\n",
+		"5_N":         "5_N\n",
+		"_s":          "1_s\n",
+		"5 N clr 5_N": "5_N\n",
+	}
+	for program, want := range stacks {
+		so := GetStackOperator(false)
+		so.ParseInput(program)
+		if s := so.Stack.Display(); s != want {
+			t.Fatalf(`program = "%s" : expected stack %q : got = %q`, program, want, s)
+		}
+	}
+	// N is the TVM register, not newtons.
+	so := GetStackOperator(false)
+	so.ParseInput("5 N")
+	if so.TVM.N != 5 || len(so.Stack.Values) != 0 {
+		t.Fatalf("expected 5 N to set the N register : got N = %v, stack = %v", so.TVM.N, so.Stack.Values)
+	}
+	StrictMode = true
+	defer func() { StrictMode = false }()
+	programs := map[string]progParams{
+		"5 km":                     {"", true, false},
+		"5_km":                     {"5_km\n", false, false},
+		"100_km/h _mph to 1 round": {"62.1_mph\n", false, false},
+	}
+	for program, params := range programs {
+		prog(t, program, params)
+	}
+}
+
+func TestFailKeepsOperands(t *testing.T) {
+	Display = true
+	programs := []string{"2026-10-16 2 round", "1_s sin", "1 2 3 irr"}
+	for _, program := range programs {
+		so := GetStackOperator(false)
+		if err := so.ParseInput(program); err == nil {
+			t.Fatalf(`program = "%s" : wanted error, none raised`, program)
+		}
+		if n := len(strings.Fields(program)) - 1; len(so.Stack.Values) != n {
+			t.Fatalf(`program = "%s" : expected %d values on the stack : got = %d`, program, n, len(so.Stack.Values))
+		}
+	}
+}
+
+func TestSeededWords(t *testing.T) {
+	Display = true
+	want := GetStackOperator(false)
+	want.Rand.Seed(1)
+	want.ParseInput("rand rand rand")
+	so := GetStackOperator(false)
+	so.Rand.Seed(1)
+	for _, p := range []string{"= r rand", "r == x rand", "x r"} {
+		if err := so.ParseInput(p); err != nil {
+			t.Fatalf("program = %q : unexpected error %q", p, err)
+		}
+	}
+	if s, w := so.Stack.Display(), want.Stack.Display(); s != w {
+		t.Fatalf("expected the same random numbers as without words %q : got = %q", w, s)
+	}
+}
@@ -23,7 +23,7 @@ func interactive(so *stack.StackOperator, color bool) (err error) {
 	}
 	defer term.Restore(int(os.Stdin.Fd()), state)
 
-	it := term.NewTerminal(os.Stdin, so.Prompt())
+	it := term.NewTerminal(os.Stdin, "")
 	ot := term.NewTerminal(os.Stdout, "")
 	et := term.NewTerminal(os.Stderr, "")
 	c := colors{}
@@ -32,6 +32,19 @@ func interactive(so *stack.StackOperator, color bool) (err error) {
 		c.err = ot.Escape.Red
 		c.reset = ot.Escape.Reset
 	}
+	// setPrompt prints the lines of the prompt above the input line, and the
+	// right prompt, and gives the input line to it to print.
+	setPrompt := func() {
+		above, line := splitPrompt(so.Prompt())
+		ot.Write([]byte(above))
+		if so.RightPrompt != nil {
+			if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+				ot.Write([]byte(rightAlign(so.RightPrompt(), width, visibleLen(line))))
+			}
+		}
+		it.SetPrompt(line)
+	}
+	setPrompt()
 	for {
 		line, err := it.ReadLine()
 		if err != nil {
@@ -41,15 +54,18 @@ func interactive(so *stack.StackOperator, color bool) (err error) {
 		if err == io.EOF {
 			return io.EOF
 		}
+		saveErr := so.Record(line, err)
 		if bytes.Count(so.ToPrint, []byte{'\n'}) < 2 {
 			ot.Write(c.out)
 		}
 		ot.Write(so.ToPrint)
-		if err != nil {
-			ot.Write(c.err)
-			et.Write([]byte(err.Error()))
+		for _, err := range []error{err, saveErr} {
+			if err != nil {
+				ot.Write(c.err)
+				et.Write([]byte(err.Error()))
+			}
 		}
 		ot.Write(c.reset)
-		it.SetPrompt(so.Prompt())
+		setPrompt()
 	}
 }
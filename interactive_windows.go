@@ -25,7 +25,14 @@ func interactive(so *stack.StackOperator, color bool) (err error) {
 		c.reset = it.Escape.Reset
 	}
 	for {
-		fmt.Print(so.Prompt())
+		above, line := splitPrompt(so.Prompt())
+		fmt.Print(above)
+		if so.RightPrompt != nil {
+			if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+				fmt.Print(rightAlign(so.RightPrompt(), width, visibleLen(line)))
+			}
+		}
+		fmt.Print(line)
 		line, err := it.ReadLine()
 		if err != nil {
 			return err
@@ -34,13 +41,16 @@ func interactive(so *stack.StackOperator, color bool) (err error) {
 		if err == io.EOF {
 			return io.EOF
 		}
+		saveErr := so.Record(line, err)
 		if bytes.Count(so.ToPrint, []byte{'\n'}) == 1 {
 			fmt.Print(string(c.out))
 		}
 		fmt.Print(string(so.ToPrint))
-		if err != nil {
-			fmt.Print(string(c.err))
-			fmt.Fprint(os.Stderr, err)
+		for _, err := range []error{err, saveErr} {
+			if err != nil {
+				fmt.Print(string(c.err))
+				fmt.Fprint(os.Stderr, err)
+			}
 		}
 		fmt.Print(string(c.reset))
 	}
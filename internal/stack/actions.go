@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/rand"
+	"math/big"
 	"slices"
 	"strings"
 )
@@ -26,18 +26,60 @@ type Action struct {
 	Help string
 }
 
+// NewAction returns an Action that calls action, which takes pops values from
+// the stack and adds pushes values to it, with help describing its purpose.
+func NewAction(action func(so *StackOperator) (toPrint string, err error), pops int, pushes int, help string) *Action {
+	return &Action{action, pops, pushes, help}
+}
+
 // Call calls the function stored in action and returns the string and error
 // value returned by that function
 func (a *Action) Call(so *StackOperator) (toPrint string, err error) {
 	return a.action(so)
 }
 
+func nonPositive(x float64) string {
+	if x <= 0 {
+		return "cannot take logarithm of non-positive number"
+	}
+	return ""
+}
+
+func outsideUnit(name string) func(float64) string {
+	return func(x float64) string {
+		if x < -1 || x > 1 {
+			return fmt.Sprintf("cannot take %s of number less than -1 or greater than 1", name)
+		}
+		return ""
+	}
+}
+
+// Functions applied by Actions to the values in the stack.
+var (
+	log10 = function{"logarithm", math.Log10, nonPositive, false, func(x float64) float64 { return 1 / (x * math.Ln10) }, false}
+
+	ln      = function{"logarithm", math.Log, nonPositive, false, func(x float64) float64 { return 1 / x }, false}
+	degrees = function{"degrees", func(x float64) float64 { return x * 180 / math.Pi }, nil, false, func(float64) float64 { return 180 / math.Pi }, false}
+
+	radians = function{"radians", func(x float64) float64 { return x * math.Pi / 180 }, nil, false, func(float64) float64 { return math.Pi / 180 }, false}
+
+	sine    = function{"sine", math.Sin, nil, false, math.Cos, false}
+	cosine  = function{"cosine", math.Cos, nil, false, func(x float64) float64 { return -math.Sin(x) }, false}
+	tangent = function{"tangent", math.Tan, nil, false, func(x float64) float64 { return 1 / (math.Cos(x) * math.Cos(x)) }, true}
+	arcsine = function{"arcsine", math.Asin, outsideUnit("arcsine"), false, func(x float64) float64 { return 1 / math.Sqrt(1-x*x) }, false}
+
+	arccosine = function{"arccosine", math.Acos, outsideUnit("arccosine"), false, func(x float64) float64 { return -1 / math.Sqrt(1-x*x) }, false}
+
+	arctangent = function{"arctangent", math.Atan, nil, false, func(x float64) float64 { return 1 / (1 + x*x) }, false}
+	floor      = function{"floor", math.Floor, nil, true, nil, false}
+	ceiling    = function{"ceiling", math.Ceil, nil, true, nil, false}
+)
+
 // Add is an Action with the following description: pop 'a', 'b'; push the
 // result of 'a' + 'b'
 var Add = &Action{
 	func(so *StackOperator) (toPrint string, err error) {
-		so.Stack.Push(so.Stack.Pop() + so.Stack.Pop())
-		return so.Stack.Display(), nil
+		return so.binary('+')
 	},
 	2, 1,
 	"Pop 'a', 'b'; push the result of summing 'a' and 'b'.",
@@ -46,10 +88,7 @@ var Add = &Action{
 // Subtract is an Action with the following description:
 var Subtract = &Action{
 	func(so *StackOperator) (string, error) {
-		x := so.Stack.Pop()
-		y := so.Stack.Pop()
-		so.Stack.Push(y - x)
-		return so.Stack.Display(), nil
+		return so.binary('-')
 	}, 2, 1,
 	"Pop 'a', 'b'; push the result of subtracting 'a' from 'b'.",
 }
@@ -58,8 +97,7 @@ var Subtract = &Action{
 // result of 'a' * 'b'
 var Multiply = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(so.Stack.Pop() * so.Stack.Pop())
-		return so.Stack.Display(), nil
+		return so.binary('*')
 	}, 2, 1,
 	"Pop 'a', 'b'; push the result of multiplying 'a' and 'b'.",
 }
@@ -68,12 +106,7 @@ var Multiply = &Action{
 // result of 'b' / 'a'
 var Divide = &Action{
 	func(so *StackOperator) (string, error) {
-		divisor := so.Stack.Pop()
-		if divisor == 0 {
-			return "", so.Fail("cannot divide by 0", divisor)
-		}
-		so.Stack.Push(so.Stack.Pop() / divisor)
-		return so.Stack.Display(), nil
+		return so.binary('/')
 	}, 2, 1,
 	"Pop 'a', 'b'; push the result of dividing 'b' by 'a'.",
 }
@@ -82,51 +115,30 @@ var Divide = &Action{
 // remainder of 'b' / 'a'.
 var Modulo = &Action{
 	func(so *StackOperator) (string, error) {
-		divisor := so.Stack.Pop()
-		if divisor == 0 {
-			return "", so.Fail("cannot divide by 0", divisor)
-		}
-		so.Stack.Push(math.Mod(so.Stack.Pop(), divisor))
-		return so.Stack.Display(), nil
+		return so.binary('%')
 	}, 2, 1,
 	"Pop 'a', 'b'; push the remainder of dividing 'b' by 'a'.",
 }
 
 // Factorial is an Action with the following description: pop 'a'; push the
-// factorial of 'a'.
+// factorial of 'a'. Non-integers use the gamma function.
 var Factorial = &Action{
 	func(so *StackOperator) (string, error) {
-		x := so.Stack.Pop()
-		if x != float64(int(x)) {
-			return "", so.Fail("cannot take factorial of non-integer", x)
-		}
-		if x < 0 {
-			return "", so.Fail("cannot take factorial of negative number", x)
-		}
-		p := 1
-		for i := 2; i <= int(x); i++ {
-			p *= i
+		if _, ok := asInt(so.Stack.Values[len(so.Stack.Values)-1]); !ok {
+			return so.unary(factorialFn)
 		}
-		so.Stack.Push(float64(p))
-		return so.Stack.Display(), nil
+		return intAction(1, func(xs []*big.Int) (*big.Int, error) {
+			return factorial(xs[0])
+		})(so)
 	}, 1, 1,
-	"Pop 'a'; push the factorial of 'a'.",
+	"Pop 'a'; push the factorial of 'a', using the gamma function for non-integers.",
 }
 
 // Power is an Action with the following description: pop 'a', 'b'; push the
 // result of 'b' ^ 'a'.
 var Power = &Action{
 	func(so *StackOperator) (string, error) {
-		exponent := so.Stack.Pop()
-		base := so.Stack.Pop()
-		if base == 0 && exponent < 0 {
-			return "", so.Fail("cannot raise 0 to negative power", base, exponent)
-		}
-		if base < 0 && exponent != float64(int(exponent)) {
-			return "", so.Fail("cannot raise negative number to non-integer power", base, exponent)
-		}
-		so.Stack.Push(math.Pow(base, exponent))
-		return so.Stack.Display(), nil
+		return so.binary('^')
 	}, 2, 1,
 	"Pop 'a', 'b'; push the result of raising 'b' to the power 'a'.",
 }
@@ -135,12 +147,7 @@ var Power = &Action{
 // base 10 of 'a'.
 var Log = &Action{
 	func(so *StackOperator) (string, error) {
-		x := so.Stack.Pop()
-		if x <= 0 {
-			return "", so.Fail("cannot take logarithm of non-positive number", x)
-		}
-		so.Stack.Push(math.Log10(x))
-		return so.Stack.Display(), nil
+		return so.unary(log10)
 	}, 1, 1,
 	"Pop 'a'; push the logarithm base 10 of 'a'.",
 }
@@ -149,12 +156,7 @@ var Log = &Action{
 // logarithm of 'a'.
 var Ln = &Action{
 	func(so *StackOperator) (string, error) {
-		x := so.Stack.Pop()
-		if x <= 0 {
-			return "", so.Fail("cannot take logarithm of non-positive number", x)
-		}
-		so.Stack.Push(math.Log(x))
-		return so.Stack.Display(), nil
+		return so.unary(ln)
 	}, 1, 1,
 	"Pop 'a'; push the natural logarithm of 'a'.",
 }
@@ -163,8 +165,7 @@ var Ln = &Action{
 // of converting 'a' from radians to degrees.
 var Degrees = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(so.Stack.Pop() * 180 / math.Pi)
-		return so.Stack.Display(), nil
+		return so.unary(degrees)
 	}, 1, 1,
 	"Pop 'a'; push the result of converting 'a' from radians to degrees.",
 }
@@ -173,86 +174,70 @@ var Degrees = &Action{
 // of converting 'a' from degrees to radians.
 var Radians = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(so.Stack.Pop() * math.Pi / 180)
-		return so.Stack.Display(), nil
+		return so.unary(radians)
 	}, 1, 1,
 	"Pop 'a'; push the result of converting 'a' from degrees to radians.",
 }
 
 // Sine is an Action with the following description: pop 'a'; push the sine of
-// 'a' in radians.
+// 'a' in the current angle mode.
 var Sine = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(math.Sin(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+		return so.trig(sine)
 	}, 1, 1,
-	"Pop 'a'; push the sine of 'a' in radians.",
+	"Pop 'a'; push the sine of 'a' in the current angle mode.",
 }
 
 // Cosine is an Action with the following description: pop 'a'; push the cosine
-// of 'a' in radians.
+// of 'a' in the current angle mode.
 var Cosine = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(math.Cos(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+		return so.trig(cosine)
 	}, 1, 1,
-	"Pop 'a'; push the cosine of 'a' in radians.",
+	"Pop 'a'; push the cosine of 'a' in the current angle mode.",
 }
 
 // Tangent is an Action with the following description: pop 'a'; push the
-// tangent of 'a' in radians.
+// tangent of 'a' in the current angle mode.
 var Tangent = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(math.Tan(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+		return so.trig(tangent)
 	}, 1, 1,
-	"Pop 'a'; push the tangent of 'a' in radians.",
+	"Pop 'a'; push the tangent of 'a' in the current angle mode.",
 }
 
 // Arcsine is an Action with the following description: Pop 'a'; push the
-// arcsine of 'a' in radians.
+// arcsine of 'a' in the current angle mode.
 var Arcsine = &Action{
-	func(so *StackOperator) (toPrint string, err error) {
-		f := so.Stack.Pop()
-		if f < -1 || f > 1 {
-			return "", so.Fail("cannot take arcsine of number less than -1 or greater than 1", f)
-		}
-		so.Stack.Push(math.Asin(f))
-		return so.Stack.Display(), nil
+	func(so *StackOperator) (string, error) {
+		return so.unary(so.Angle.inverse(arcsine))
 	}, 1, 1,
-	"Pop 'a'; push the arcsine of 'a' in radians.",
+	"Pop 'a'; push the arcsine of 'a' in the current angle mode.",
 }
 
 // Arccosine is an Action with the following description: Pop 'a'; push the
-// arccosine of 'a' in radians.
+// arccosine of 'a' in the current angle mode.
 var Arccosine = &Action{
-	func(so *StackOperator) (toPrint string, err error) {
-		f := so.Stack.Pop()
-		if f < -1 || f > 1 {
-			return "", so.Fail("cannot take arccosine of number less than -1 or greater than 1", f)
-		}
-		so.Stack.Push(math.Acos(f))
-		return so.Stack.Display(), nil
+	func(so *StackOperator) (string, error) {
+		return so.unary(so.Angle.inverse(arccosine))
 	}, 1, 1,
-	"Pop 'a'; push the arccosine of 'a' in radians.",
+	"Pop 'a'; push the arccosine of 'a' in the current angle mode.",
 }
 
 // Arctangent is an Action with the following description: Pop 'a'; push the
-// argtangent of 'a' in radians.
+// argtangent of 'a' in the current angle mode.
 var Arctangent = &Action{
-	func(so *StackOperator) (toPrint string, err error) {
-		so.Stack.Push(math.Atan(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+	func(so *StackOperator) (string, error) {
+		return so.unary(so.Angle.inverse(arctangent))
 	}, 1, 1,
-	"Pop 'a'; push the argtangent of 'a' in radians.",
+	"Pop 'a'; push the argtangent of 'a' in the current angle mode.",
 }
 
 // Floor is an Action with the following description: pop 'a'; push the greatest
 // integer value less than or equal to 'a'.
 var Floor = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(math.Floor(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+		return so.unary(floor)
 	}, 1, 1,
 	"Pop 'a'; push the greatest integer value less than or equal to 'a'.",
 }
@@ -261,8 +246,7 @@ var Floor = &Action{
 // integer value greater than or equal to 'a'.
 var Ceiling = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(math.Ceil(so.Stack.Pop()))
-		return so.Stack.Display(), nil
+		return so.unary(ceiling)
 	}, 1, 1,
 	"Pop 'a'; push the least integer value greater than or equal to 'a'.",
 }
@@ -271,12 +255,20 @@ var Ceiling = &Action{
 // result of rounding 'b' to 'a' decimal places.
 var Round = &Action{
 	func(so *StackOperator) (string, error) {
-		precision := so.Stack.Pop()
+		precision, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
 		if precision < 0 || precision != float64(int(precision)) {
-			return "", so.Fail("precision must be non-negative integer")
+			return "", so.Fail("precision must be non-negative integer", Number(precision))
 		}
 		ratio := math.Pow(10, precision)
-		so.Stack.Push(math.Round(so.Stack.Pop()*ratio) / ratio)
+		x := so.Stack.Pop()
+		y, err := apply(function{"round", func(x float64) float64 { return math.Round(x*ratio) / ratio }, nil, true, nil, false}, x)
+		if err != nil {
+			return "", so.Fail(err.Error(), x, Number(precision))
+		}
+		so.Stack.Push(y)
 		return so.Stack.Display(), nil
 	}, 2, 1,
 	"Pop 'a', 'b'; push the result of rounding 'b' to 'a' decimal places.",
@@ -286,7 +278,7 @@ var Round = &Action{
 // between 0 and 1.
 var Random = &Action{
 	func(so *StackOperator) (string, error) {
-		so.Stack.Push(rand.Float64())
+		so.Stack.Push(Number(so.Rand.Float64()))
 		return so.Stack.Display(), nil
 	}, 0, 1,
 	"Push a random number between 0 and 1.",
@@ -316,8 +308,8 @@ var Pull = &Action{
 var Display = &Action{
 	func(so *StackOperator) (string, error) {
 		sBuf := make([]string, len(so.Stack.Values))
-		for i, f := range so.Stack.Values {
-			sBuf[i] = fmt.Sprint(f)
+		for i, v := range so.Stack.Values {
+			sBuf[i] = v.String()
 		}
 		return fmt.Sprintf("[ %s ]\n", strings.Join(sBuf, " ")), nil
 	}, 0, 0,
@@ -411,9 +403,8 @@ func getWordVal(so *StackOperator, word string) (val string, sep byte) {
 	if val, pres := so.Words[word]; pres {
 		return val, ':'
 	}
-	if f, pres := so.ValWords[word]; pres {
-		val = fmt.Sprint(f)
-		return val, '='
+	if v, pres := so.ValWords[word]; pres {
+		return v.String(), '='
 	}
 	return word, '|'
 }
@@ -436,7 +427,7 @@ var Clear = &Action{
 		if n != 1 {
 			c = 's'
 		}
-		so.Stack.Values = make([]float64, 0, cap(so.Stack.Values))
+		so.Stack.Values = make([]Value, 0, cap(so.Stack.Values))
 		return fmt.Sprintf("cleared %d value%c\n", n, c), nil
 	}, 0, 0,
 	"Pop all values in the stack.",
@@ -468,11 +459,11 @@ var Swap = &Action{
 // right one position.
 var Froll = &Action{
 	func(so *StackOperator) (string, error) {
-		newVals := make([]float64, 0, cap(so.Stack.Values))
+		newVals := make([]Value, 0, cap(so.Stack.Values))
 		l := len(so.Stack.Values)
 		newVals = append(newVals, so.Stack.Values[l-1])
-		for _, f := range so.Stack.Values[:l-1] {
-			newVals = append(newVals, f)
+		for _, v := range so.Stack.Values[:l-1] {
+			newVals = append(newVals, v)
 		}
 		so.Stack.Values = newVals
 		return so.Stack.Display(), nil
@@ -484,9 +475,9 @@ var Froll = &Action{
 // one position.
 var Rroll = &Action{
 	func(so *StackOperator) (string, error) {
-		newVals := make([]float64, 0, cap(so.Stack.Values))
-		for _, f := range so.Stack.Values[1:] {
-			newVals = append(newVals, f)
+		newVals := make([]Value, 0, cap(so.Stack.Values))
+		for _, v := range so.Stack.Values[1:] {
+			newVals = append(newVals, v)
 		}
 		newVals = append(newVals, so.Stack.Values[0])
 		so.Stack.Values = newVals
@@ -499,10 +490,13 @@ var Rroll = &Action{
 // push their sum.
 var Sum = &Action{
 	func(so *StackOperator) (toPrint string, err error) {
-		var sum float64
-		for len(so.Stack.Values) > 0 {
-			sum += so.Stack.Pop()
+		sum := so.Stack.Values[0]
+		for _, v := range so.Stack.Values[1:] {
+			if sum, err = arith('+', sum, v); err != nil {
+				return "", so.Fail(err.Error())
+			}
 		}
+		so.Stack.Values = so.Stack.Values[:0]
 		so.Stack.Push(sum)
 		return so.Stack.Display(), nil
 	}, 1, 1,
@@ -513,9 +507,16 @@ var Sum = &Action{
 // stack; push their average.
 var Average = &Action{
 	func(so *StackOperator) (toPrint string, err error) {
-		n := float64(len(so.Stack.Values))
-		Sum.Call(so)
-		so.Stack.Push(so.Stack.Pop() / n)
+		n := Number(len(so.Stack.Values))
+		if _, err := Sum.Call(so); err != nil {
+			return "", err
+		}
+		sum := so.Stack.Pop()
+		avg, err := arith('/', sum, n)
+		if err != nil {
+			return "", so.Fail(err.Error(), sum)
+		}
+		so.Stack.Push(avg)
 		return so.Stack.Display(), nil
 	}, 1, 1,
 	"Pop all values in the stack; push their average.",
@@ -549,14 +550,17 @@ var Grow = &Action{
 		if len(so.Stack.Values) == 0 {
 			return "", nil
 		}
-		n := so.Stack.Pop()
+		n, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
 		if n != float64(int(n)) {
-			return "", so.Fail("cannot grow stack by non-integer value", n)
+			return "", so.Fail("cannot grow stack by non-integer value", Number(n))
 		}
 		if n < 0 {
-			return "", so.Fail("cannot grow stack by negative value", n)
+			return "", so.Fail("cannot grow stack by negative value", Number(n))
 		}
-		so.Stack.Push(n)
+		so.Stack.Push(Number(n))
 		so.Stack.Values = slices.Grow(so.Stack.Values, int(n))
 		return fmt.Sprintf("new stack capacity is %d\n", cap(so.Stack.Values)), nil
 	}, 0, 0,
@@ -569,7 +573,7 @@ var Fill = &Action{
 	func(so *StackOperator) (toPrint string, err error) {
 		for i := 0; i < cap(so.Stack.Values); i++ {
 			if i > len(so.Stack.Values)-1 {
-				so.Stack.Values = append(so.Stack.Values, float64(rand.Intn(255)))
+				so.Stack.Values = append(so.Stack.Values, Number(so.Rand.Intn(255)))
 			}
 		}
 		return so.Stack.Display(), nil
@@ -0,0 +1,140 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"math"
+)
+
+// AngleMode is the unit that trigonometric Actions measure angles in.
+type AngleMode byte
+
+const (
+	RadianMode AngleMode = iota
+	DegreeMode
+	GradianMode
+)
+
+func (m AngleMode) String() string {
+	switch m {
+	case DegreeMode:
+		return "DEG"
+	case GradianMode:
+		return "GRAD"
+	}
+	return "RAD"
+}
+
+// name returns the plural name of the unit m measures angles in.
+func (m AngleMode) name() string {
+	switch m {
+	case DegreeMode:
+		return "degrees"
+	case GradianMode:
+		return "gradians"
+	}
+	return "radians"
+}
+
+// quarterTurn returns the size of a right angle in m.
+func (m AngleMode) quarterTurn() float64 {
+	switch m {
+	case DegreeMode:
+		return 90
+	case GradianMode:
+		return 100
+	}
+	return math.Pi / 2
+}
+
+// forward returns fn, which takes an angle in radians, changed to take an angle
+// in m. Outside of radian mode, angles that are a whole number of right angles
+// give exact results, so 180 sin is 0 and 90 tan is an error.
+func (m AngleMode) forward(fn function) function {
+	if m == RadianMode {
+		return fn
+	}
+	q := m.quarterTurn()
+	f, d := fn.f, fn.deriv
+	fn.f = func(x float64) float64 {
+		y := f(x / q * math.Pi / 2)
+		if math.Mod(x, q) == 0 {
+			// Adding 0 turns -0 into 0.
+			return math.Round(y) + 0
+		}
+		return y
+	}
+	if d != nil {
+		fn.deriv = func(x float64) float64 { return d(x/q*math.Pi/2) * math.Pi / 2 / q }
+	}
+	if fn.poles {
+		fn.domain = func(x float64) string {
+			if math.Mod(x, 2*q) != 0 && math.Mod(x, q) == 0 {
+				return fmt.Sprintf("cannot take %s of odd multiple of %v %s", fn.name, q, m.name())
+			}
+			return ""
+		}
+	}
+	return fn
+}
+
+// inverse returns fn, which returns an angle in radians, changed to return an
+// angle in m.
+func (m AngleMode) inverse(fn function) function {
+	if m == RadianMode {
+		return fn
+	}
+	q := m.quarterTurn()
+	f, d := fn.f, fn.deriv
+	fn.f = func(x float64) float64 { return f(x) / (math.Pi / 2) * q }
+	if d != nil {
+		fn.deriv = func(x float64) float64 { return d(x) / (math.Pi / 2) * q }
+	}
+	return fn
+}
+
+// trig pops 'a' and pushes fn, which takes an angle in radians, of 'a' in the
+// current angle mode.
+func (so *StackOperator) trig(fn function) (string, error) {
+	iv, ok := so.Stack.Values[len(so.Stack.Values)-1].(Interval)
+	if !ok {
+		return so.unary(so.Angle.forward(fn))
+	}
+	so.Stack.Pop()
+	v, err := iv.trig(fn, so.Angle)
+	if err != nil {
+		return "", so.Fail(err.Error(), iv)
+	}
+	so.Stack.Push(v)
+	return so.Stack.Display(), nil
+}
+
+func angleModeAction(m AngleMode) func(so *StackOperator) (string, error) {
+	return func(so *StackOperator) (string, error) {
+		so.Angle = m
+		return fmt.Sprintf("angle mode: %s\n", m.name()), nil
+	}
+}
+
+// DegMode is an Action with the following description: measure angles in
+// degrees.
+var DegMode = &Action{
+	angleModeAction(DegreeMode), 0, 0,
+	"Measure angles in degrees.",
+}
+
+// RadMode is an Action with the following description: measure angles in
+// radians.
+var RadMode = &Action{
+	angleModeAction(RadianMode), 0, 0,
+	"Measure angles in radians.",
+}
+
+// GradMode is an Action with the following description: measure angles in
+// gradians.
+var GradMode = &Action{
+	angleModeAction(GradianMode), 0, 0,
+	"Measure angles in gradians.",
+}
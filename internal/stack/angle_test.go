@@ -0,0 +1,30 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import "testing"
+
+func TestAnglePoles(t *testing.T) {
+	// The poles of tangent must not depend on the name it is shown with.
+	tan := tangent
+	tan.name = "tan"
+	for _, m := range []AngleMode{DegreeMode, GradianMode} {
+		q := m.quarterTurn()
+		f := m.forward(tan)
+		for _, x := range []float64{q, -q, 3 * q} {
+			if _, err := f.call(x); err == nil {
+				t.Fatalf("%v tan %v : expected error", m, x)
+			}
+		}
+		if y, err := f.call(2 * q); err != nil || y != 0 {
+			t.Fatalf("%v tan %v : expected = 0 : got = %v, %v", m, 2*q, y, err)
+		}
+		if _, err := (Interval{q / 2, 3 * q / 2}).trig(tan, m); err == nil {
+			t.Fatalf("%v tan [%v,%v] : expected error", m, q/2, 3*q/2)
+		}
+		if _, err := m.forward(sine).call(q); err != nil {
+			t.Fatalf("%v sin %v : unexpected error %q", m, q, err)
+		}
+	}
+}
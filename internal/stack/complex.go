@@ -0,0 +1,109 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// Complex is a complex number, written like 1+2i or (1+2i).
+type Complex complex128
+
+// newComplex returns c as a Complex, or as a Number if it has no imaginary
+// part.
+func newComplex(c complex128) Value {
+	if imag(c) == 0 {
+		return Number(real(c))
+	}
+	return Complex(c)
+}
+
+func (c Complex) String() string { return strconv.FormatComplex(complex128(c), 'g', -1, 128) }
+
+func (Complex) kind() string { return "complex number" }
+
+func (c Complex) format(prec int) string {
+	return strconv.FormatComplex(complex128(c), 'g', prec, 128)
+}
+
+func (c Complex) arith(op byte, y Value, reversed bool) (Value, error) {
+	var d complex128
+	switch y := y.(type) {
+	case Complex:
+		d = complex128(y)
+	default:
+		f, ok := asFloat(y)
+		if !ok {
+			return nil, errUnsupported
+		}
+		d = complex(f, 0)
+	}
+	a, b := complex128(c), d
+	if reversed {
+		a, b = b, a
+	}
+	switch op {
+	case '+':
+		return newComplex(a + b), nil
+	case '-':
+		return newComplex(a - b), nil
+	case '*':
+		return newComplex(a * b), nil
+	case '/':
+		if b == 0 {
+			return nil, errors.New("cannot divide by 0")
+		}
+		return newComplex(a / b), nil
+	case '^':
+		if a == 0 && real(b) < 0 {
+			return nil, errors.New("cannot raise 0 to negative power")
+		}
+		if n := real(b); imag(b) == 0 && n == math.Trunc(n) && math.Abs(n) <= 64 {
+			return newComplex(intPow(a, int(n))), nil
+		}
+		return newComplex(cmplx.Pow(a, b)), nil
+	}
+	return nil, errUnsupported
+}
+
+// intPow returns c^n by repeated squaring, which is exact where cmplx.Pow is not.
+func intPow(c complex128, n int) complex128 {
+	if n < 0 {
+		return 1 / intPow(c, -n)
+	}
+	p := complex(1, 0)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			p *= c
+		}
+		c *= c
+	}
+	return p
+}
+
+// parseComplex returns the complex number written as s and true, or false if s
+// is not a complex number ending in i.
+func parseComplex(s string) (Complex, bool) {
+	if !strings.HasSuffix(strings.TrimSuffix(s, ")"), "i") {
+		return 0, false
+	}
+	c, err := strconv.ParseComplex(s, 128)
+	if err != nil {
+		return 0, false
+	}
+	return Complex(c), true
+}
+
+// asComplex returns v as a complex128 if v is a Complex or a number.
+func asComplex(v Value) (complex128, bool) {
+	if c, ok := v.(Complex); ok {
+		return complex128(c), true
+	}
+	f, ok := asFloat(v)
+	return complex(f, 0), ok
+}
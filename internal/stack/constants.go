@@ -0,0 +1,103 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+type constant struct {
+	name   string
+	val    float64
+	units  string
+	source string
+	desc   string
+}
+
+// constantCatalog contains every built-in constant. Constants are namespaced
+// so they do not get in the way of words: physical constants start with
+// "phys." and mathematical constants start with "math.".
+var constantCatalog = []constant{
+	{"phys.c", 299792458, "m/s", "CODATA 2018 (exact)", "speed of light in vacuum"},
+	{"phys.h", 6.62607015e-34, "J*s", "CODATA 2018 (exact)", "Planck constant"},
+	{"phys.hbar", 1.054571817e-34, "J*s", "CODATA 2018", "reduced Planck constant"},
+	{"phys.e", 1.602176634e-19, "C", "CODATA 2018 (exact)", "elementary charge"},
+	{"phys.k_B", 1.380649e-23, "J/K", "CODATA 2018 (exact)", "Boltzmann constant"},
+	{"phys.N_A", 6.02214076e23, "1/mol", "CODATA 2018 (exact)", "Avogadro constant"},
+	{"phys.R", 8.314462618, "J/mol/K", "CODATA 2018", "molar gas constant"},
+	{"phys.G", 6.67430e-11, "m^3/kg/s^2", "CODATA 2018", "Newtonian constant of gravitation"},
+	{"phys.g", 9.80665, "m/s^2", "CGPM 1901 (exact)", "standard acceleration of gravity"},
+	{"phys.m_e", 9.1093837015e-31, "kg", "CODATA 2018", "electron mass"},
+	{"phys.m_p", 1.67262192369e-27, "kg", "CODATA 2018", "proton mass"},
+	{"phys.m_n", 1.67492749804e-27, "kg", "CODATA 2018", "neutron mass"},
+	{"phys.m_u", 1.66053906660e-27, "kg", "CODATA 2018", "atomic mass constant"},
+	{"phys.eps_0", 8.8541878128e-12, "F/m", "CODATA 2018", "vacuum electric permittivity"},
+	{"phys.mu_0", 1.25663706212e-6, "N/A^2", "CODATA 2018", "vacuum magnetic permeability"},
+	{"phys.sigma", 5.670374419e-8, "W/m^2/K^4", "CODATA 2018", "Stefan-Boltzmann constant"},
+	{"phys.alpha", 7.2973525693e-3, "", "CODATA 2018", "fine-structure constant"},
+	{"phys.a_0", 5.29177210903e-11, "m", "CODATA 2018", "Bohr radius"},
+	{"phys.R_inf", 10973731.568160, "1/m", "CODATA 2018", "Rydberg constant"},
+	{"phys.atm", 101325, "Pa", "CGPM 1954 (exact)", "standard atmosphere"},
+	{"math.pi", math.Pi, "", "mathematical", "ratio of circumference to diameter"},
+	{"math.tau", 2 * math.Pi, "", "mathematical", "ratio of circumference to radius"},
+	{"math.e", math.E, "", "mathematical", "base of the natural logarithm"},
+	{"math.phi", math.Phi, "", "mathematical", "golden ratio"},
+	{"math.sqrt2", math.Sqrt2, "", "mathematical", "square root of 2"},
+	{"math.sqrt3", math.Sqrt(3), "", "mathematical", "square root of 3"},
+	{"math.ln2", math.Ln2, "", "mathematical", "natural logarithm of 2"},
+	{"math.ln10", math.Ln10, "", "mathematical", "natural logarithm of 10"},
+	{"math.gamma", 0.57721566490153286060, "", "mathematical", "Euler-Mascheroni constant"},
+}
+
+var (
+	constantsOnce sync.Once
+	constants     map[string]Value
+)
+
+// loadConstants builds the values of every constant in constantCatalog. It is
+// only called the first time a constant is needed.
+func loadConstants() {
+	// Constants only use built-in units, so any StackOperator will do.
+	so := new(StackOperator)
+	constants = make(map[string]Value, len(constantCatalog))
+	for _, c := range constantCatalog {
+		if c.units == "" {
+			constants[c.name] = Number(c.val)
+			continue
+		}
+		u, err := so.parseUnit(c.units)
+		if err != nil {
+			panic(fmt.Sprintf("bad units for constant %s: %v", c.name, err))
+		}
+		constants[c.name] = newQuantity(c.val, u)
+	}
+}
+
+// lookupConstant returns the value of the constant called name and true, or
+// false if there is no such constant.
+func lookupConstant(name string) (Value, bool) {
+	if !strings.HasPrefix(name, "phys.") && !strings.HasPrefix(name, "math.") {
+		return nil, false
+	}
+	constantsOnce.Do(loadConstants)
+	v, pres := constants[name]
+	return v, pres
+}
+
+// Constants is an Action with the following description: display all built-in
+// constants with their units and source.
+var Constants = &Action{
+	func(so *StackOperator) (string, error) {
+		sb := new(strings.Builder)
+		sb.WriteString(fmt.Sprintf("%-11s %-22s %-11s %-20s %s\n", "constant", "value", "units", "source", "description"))
+		for _, c := range constantCatalog {
+			sb.WriteString(fmt.Sprintf("%-11s %-22v %-11s %-20s %s\n", c.name, c.val, c.units, c.source, c.desc))
+		}
+		return sb.String(), nil
+	}, 0, 0,
+	"Display all built-in constants with their units and source.",
+}
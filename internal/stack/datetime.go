@@ -0,0 +1,299 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Time is a point in time.
+type Time struct {
+	T time.Time
+}
+
+func (t Time) String() string {
+	if t.T.Location() != time.Local {
+		return t.T.Format(time.RFC3339Nano)
+	}
+	h, m, s := t.T.Clock()
+	switch {
+	case t.T.Nanosecond() != 0:
+		return t.T.Format("2006-01-02T15:04:05.999999999")
+	case s != 0:
+		return t.T.Format("2006-01-02T15:04:05")
+	case h != 0 || m != 0:
+		return t.T.Format("2006-01-02T15:04")
+	}
+	return t.T.Format("2006-01-02")
+}
+
+func (Time) kind() string { return "time" }
+
+// addDuration returns t plus d. Whole days of d are added as calendar days, so
+// that adding a day keeps the time of day across daylight saving changes.
+func addDuration(t time.Time, d time.Duration) time.Time {
+	days := d / (24 * time.Hour)
+	return t.AddDate(0, 0, int(days)).Add(d - days*24*time.Hour)
+}
+
+// dayNumber returns the number of calendar days from 1 January 1970 to t, in
+// the location of t, plus the fraction of the day on its clock.
+func dayNumber(t time.Time) float64 {
+	y, m, d := t.Date()
+	days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	h, min, sec := t.Clock()
+	clock := time.Duration(h)*time.Hour + time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+	return float64(days) + clock.Hours()/24
+}
+
+func (t Time) arith(op byte, y Value, reversed bool) (Value, error) {
+	if d, ok := asDuration(y); ok {
+		switch {
+		case op == '+':
+			return Time{addDuration(t.T, d)}, nil
+		case op == '-' && !reversed:
+			return Time{addDuration(t.T, -d)}, nil
+		}
+	}
+	if u, ok := y.(Time); ok && op == '-' {
+		if reversed {
+			return Duration(u.T.Sub(t.T)), nil
+		}
+		return Duration(t.T.Sub(u.T)), nil
+	}
+	return nil, errUnsupported
+}
+
+// Duration is a length of time.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	if d == 0 {
+		return "0s"
+	}
+	sb := new(strings.Builder)
+	n := time.Duration(d)
+	if n < 0 {
+		sb.WriteByte('-')
+		n = -n
+	}
+	for _, u := range []struct {
+		sym  string
+		size time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}} {
+		if k := n / u.size; k > 0 {
+			sb.WriteString(fmt.Sprintf("%d%s", k, u.sym))
+			n -= k * u.size
+		}
+	}
+	if n > 0 {
+		sb.WriteString(strconv.FormatFloat(n.Seconds(), 'f', -1, 64) + "s")
+	}
+	return sb.String()
+}
+
+func (Duration) kind() string { return "duration" }
+
+func (d Duration) arith(op byte, y Value, reversed bool) (Value, error) {
+	x := time.Duration(d)
+	if e, ok := asDuration(y); ok {
+		if reversed {
+			x, e = e, x
+		}
+		switch op {
+		case '+':
+			return Duration(x + e), nil
+		case '-':
+			return Duration(x - e), nil
+		case '/':
+			if e == 0 {
+				return nil, errors.New("cannot divide by 0")
+			}
+			return Number(float64(x) / float64(e)), nil
+		case '%':
+			if e == 0 {
+				return nil, errors.New("cannot divide by 0")
+			}
+			return Duration(x % e), nil
+		}
+		return nil, errUnsupported
+	}
+	n, ok := y.(Number)
+	if !ok {
+		return nil, errUnsupported
+	}
+	f := float64(n)
+	switch {
+	case op == '*':
+		return Duration(math.Round(float64(x) * f)), nil
+	case op == '/' && !reversed:
+		if f == 0 {
+			return nil, errors.New("cannot divide by 0")
+		}
+		return Duration(math.Round(float64(x) / f)), nil
+	}
+	return nil, errUnsupported
+}
+
+// asDuration returns v as a time.Duration if v is a Duration or a quantity of
+// time.
+func asDuration(v Value) (time.Duration, bool) {
+	switch v := v.(type) {
+	case Duration:
+		return time.Duration(v), true
+	case Quantity:
+		if v.Unit.dim == (dimension{0, 0, 1}) {
+			return time.Duration(math.Round(v.Val * v.Unit.factor * float64(time.Second))), true
+		}
+	}
+	return 0, false
+}
+
+// Zone is a time zone.
+type Zone struct {
+	Loc *time.Location
+}
+
+func (z Zone) String() string { return z.Loc.String() }
+
+func (Zone) kind() string { return "time zone" }
+
+// parseZone returns the time zone called name and true, or false if name is
+// not an IANA time zone name, "UTC", or "Local".
+func parseZone(name string) (Zone, bool) {
+	if !strings.Contains(name, "/") && name != "UTC" && name != "Local" {
+		return Zone{}, false
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return Zone{}, false
+	}
+	return Zone{loc}, true
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var clockLayouts = []string{"15:04:05", "15:04"}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// parseTime parses dates like 2026-10-16, date-times like 2026-10-16T14:30,
+// and times of day like 14:30, which fall on the current day.
+func parseTime(s string) (Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return Time{t}, true
+		}
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			y, m, d := time.Now().Date()
+			return Time{time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, time.Local)}, true
+		}
+	}
+	return Time{}, false
+}
+
+// parseDuration parses durations like 3d or 2h30m. Units are w, d, h, m, s, ms,
+// us, and ns.
+func parseDuration(s string) (Duration, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return 0, false
+	}
+	isNum := func(c byte) bool { return c >= '0' && c <= '9' || c == '.' }
+	var total float64
+	for s != "" {
+		i := 0
+		for i < len(s) && isNum(s[i]) {
+			i++
+		}
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, false
+		}
+		j := i
+		for j < len(s) && !isNum(s[j]) {
+			j++
+		}
+		unit, pres := durationUnits[s[i:j]]
+		if !pres {
+			return 0, false
+		}
+		total += n * float64(unit)
+		s = s[j:]
+	}
+	if neg {
+		total = -total
+	}
+	return Duration(math.Round(total)), true
+}
+
+// Now is an Action with the following description: push the current time.
+var Now = &Action{
+	func(so *StackOperator) (string, error) {
+		so.Stack.Push(Time{time.Now().Round(0).Truncate(time.Second)})
+		return so.Stack.Display(), nil
+	}, 0, 1,
+	"Push the current time.",
+}
+
+// DayOfWeek is an Action with the following description: pop 'a'; push the day
+// of the week of 'a', from 1 for Monday to 7 for Sunday.
+var DayOfWeek = &Action{
+	func(so *StackOperator) (string, error) {
+		v := so.Stack.Pop()
+		t, ok := v.(Time)
+		if !ok {
+			return "", so.Fail(fmt.Sprintf("cannot take day of week of %s", v.kind()), v)
+		}
+		day := t.T.Weekday()
+		if day == time.Sunday {
+			day = 7
+		}
+		so.Stack.Push(Number(day))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the day of the week of 'a', from 1 for Monday to 7 for Sunday.",
+}
+
+// DaysBetween is an Action with the following description: pop 'a', 'b'; push
+// the number of days from 'b' to 'a'.
+var DaysBetween = &Action{
+	func(so *StackOperator) (string, error) {
+		y := so.Stack.Pop()
+		x := so.Stack.Pop()
+		a, aOk := y.(Time)
+		b, bOk := x.(Time)
+		if !aOk || !bOk {
+			return "", so.Fail(fmt.Sprintf("cannot count days between %s and %s", x.kind(), y.kind()), x, y)
+		}
+		so.Stack.Push(Number(dayNumber(a.T) - dayNumber(b.T)))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push the number of days from 'b' to 'a'.",
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddDuration(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks in New York went forward on 10 March 2024.
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, ny)
+	tests := []struct {
+		d    time.Duration
+		want time.Time
+	}{
+		{24 * time.Hour, time.Date(2024, 3, 10, 12, 0, 0, 0, ny)},
+		{36 * time.Hour, time.Date(2024, 3, 11, 0, 0, 0, 0, ny)},
+		{-24 * time.Hour, time.Date(2024, 3, 8, 12, 0, 0, 0, ny)},
+		{90 * time.Minute, time.Date(2024, 3, 9, 13, 30, 0, 0, ny)},
+	}
+	for _, test := range tests {
+		if got := addDuration(start, test.d); !got.Equal(test.want) {
+			t.Fatalf("%v + %v : expected = %v : got = %v", start, test.d, test.want, got)
+		}
+	}
+}
+
+func TestDayNumber(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want float64
+	}{
+		{time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(1970, 1, 2, 18, 0, 0, 0, time.UTC), 1.75},
+		{time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC), -0.5},
+		// The day is counted in the location of the time, not in UTC.
+		{time.Date(1970, 1, 2, 6, 0, 0, 0, time.FixedZone("", 10*60*60)), 1.25},
+	}
+	for _, test := range tests {
+		if got := dayNumber(test.t); got != test.want {
+			t.Fatalf("%v : expected = %v : got = %v", test.t, test.want, got)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"2h30m", 2*time.Hour + 30*time.Minute, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"-1w", -7 * 24 * time.Hour, true},
+		{"250ms", 250 * time.Millisecond, true},
+		{"3", 0, false},
+		{"3x", 0, false},
+		{"-", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := parseDuration(test.s)
+		if ok != test.ok || time.Duration(got) != test.want {
+			t.Fatalf("%s : expected = %v, %v : got = %v, %v", test.s, test.want, test.ok, time.Duration(got), ok)
+		}
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	tests := map[Duration]string{
+		0:                                       "0s",
+		Duration(26*time.Hour + 90*time.Second): "1d2h1m30s",
+		Duration(-1500 * time.Millisecond):      "-1.5s",
+	}
+	for d, want := range tests {
+		if got := d.String(); got != want {
+			t.Fatalf("expected = %s : got = %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TVM contains the time value of money registers. I is the nominal annual
+// interest rate in percent and PY is the number of payments per year.
+type TVM struct {
+	N, I, PV, PMT, FV, PY float64
+	// Begin signifies whether payments are made at the beginning of each
+	// period instead of the end.
+	Begin bool
+}
+
+func newTVM() *TVM {
+	return &TVM{PY: 12}
+}
+
+// rate returns the periodic interest rate as a fraction.
+func (tvm *TVM) rate() float64 {
+	return tvm.I / 100 / tvm.PY
+}
+
+// due returns the factor applied to payments for the current payment mode.
+func (tvm *TVM) due(i float64) float64 {
+	if tvm.Begin {
+		return 1 + i
+	}
+	return 1
+}
+
+// balance returns the value of the TVM equation for periodic rate i. The
+// registers are solved when this is 0.
+func (tvm *TVM) balance(i float64) float64 {
+	if i == 0 {
+		return tvm.PV + tvm.PMT*tvm.N + tvm.FV
+	}
+	g := math.Pow(1+i, tvm.N)
+	return tvm.PV*g + tvm.PMT*tvm.due(i)*(g-1)/i + tvm.FV
+}
+
+func (tvm *TVM) solveFV() (float64, error) {
+	i := tvm.rate()
+	if i == 0 {
+		return -(tvm.PV + tvm.PMT*tvm.N), nil
+	}
+	g := math.Pow(1+i, tvm.N)
+	return -(tvm.PV*g + tvm.PMT*tvm.due(i)*(g-1)/i), nil
+}
+
+func (tvm *TVM) solvePV() (float64, error) {
+	i := tvm.rate()
+	if i == 0 {
+		return -(tvm.FV + tvm.PMT*tvm.N), nil
+	}
+	g := math.Pow(1+i, tvm.N)
+	return -(tvm.FV + tvm.PMT*tvm.due(i)*(g-1)/i) / g, nil
+}
+
+func (tvm *TVM) solvePMT() (float64, error) {
+	if tvm.N == 0 {
+		return 0, errors.New("cannot solve for PMT with N = 0")
+	}
+	i := tvm.rate()
+	if i == 0 {
+		return -(tvm.FV + tvm.PV) / tvm.N, nil
+	}
+	g := math.Pow(1+i, tvm.N)
+	return -(tvm.FV + tvm.PV*g) * i / (tvm.due(i) * (g - 1)), nil
+}
+
+func (tvm *TVM) solveN() (float64, error) {
+	i := tvm.rate()
+	if i == 0 {
+		if tvm.PMT == 0 {
+			return 0, errors.New("cannot solve for N with PMT = 0 and no interest")
+		}
+		return -(tvm.PV + tvm.FV) / tvm.PMT, nil
+	}
+	k := tvm.PMT * tvm.due(i) / i
+	g := (k - tvm.FV) / (tvm.PV + k)
+	if g <= 0 || math.IsInf(g, 0) || math.IsNaN(g) {
+		return 0, errors.New("no solution for N")
+	}
+	return math.Log(g) / math.Log(1+i), nil
+}
+
+func (tvm *TVM) solveI() (float64, error) {
+	i, err := findRate(tvm.balance, 0.1/tvm.PY)
+	if err != nil {
+		return 0, errors.New("no solution for I/YR")
+	}
+	return i * 100 * tvm.PY, nil
+}
+
+// findRate uses the secant method to find the rate at which f returns 0,
+// starting from guess.
+func findRate(f func(float64) float64, guess float64) (float64, error) {
+	x0, x1 := guess, guess*1.1
+	f0, f1 := f(x0), f(x1)
+	for n := 0; n < 200; n++ {
+		if f1 == f0 {
+			break
+		}
+		x2 := x1 - f1*(x1-x0)/(f1-f0)
+		if x2 <= -1 {
+			x2 = (x1 - 1) / 2
+		}
+		if math.Abs(x2-x1) < 1e-14*math.Max(1, math.Abs(x2)) {
+			return x2, nil
+		}
+		x0, f0 = x1, f1
+		x1, f1 = x2, f(x2)
+	}
+	if math.Abs(f1) < 1e-9 {
+		return x1, nil
+	}
+	return 0, errors.New("rate did not converge")
+}
+
+// String returns the contents of every TVM register.
+func (tvm *TVM) String() string {
+	mode := "END"
+	if tvm.Begin {
+		mode = "BEG"
+	}
+	return fmt.Sprintf("N = %v\nI/YR = %v\nPV = %v\nPMT = %v\nFV = %v\nP/YR = %v\nmode = %s\n",
+		tvm.N, tvm.I, tvm.PV, tvm.PMT, tvm.FV, tvm.PY, mode)
+}
+
+func tvmStore(name string, register func(*TVM) *float64) *Action {
+	return &Action{
+		func(so *StackOperator) (string, error) {
+			f, err := so.popFloat()
+			if err != nil {
+				return "", so.Fail(err.Error())
+			}
+			if name == "P/YR" && f <= 0 {
+				return "", so.Fail("P/YR must be positive", Number(f))
+			}
+			*register(so.TVM) = f
+			return fmt.Sprintf("%s = %v\n", name, f), nil
+		}, 1, 0,
+		fmt.Sprintf("Pop 'a'; store 'a' in the %s register.", name),
+	}
+}
+
+func tvmSolve(name string, register func(*TVM) *float64, solve func(*TVM) (float64, error)) *Action {
+	return &Action{
+		func(so *StackOperator) (string, error) {
+			f, err := solve(so.TVM)
+			if err != nil {
+				return "", so.Fail(err.Error())
+			}
+			*register(so.TVM) = f
+			so.Stack.Push(Number(f))
+			return so.Stack.Display(), nil
+		}, 0, 1,
+		fmt.Sprintf("Solve for the %s register from the other registers; push the result.", name),
+	}
+}
+
+// StoreN, StoreI, StorePV, StorePMT, StoreFV, and StorePY are Actions with the
+// following description: pop 'a'; store 'a' in the register.
+var (
+	StoreN   = tvmStore("N", func(tvm *TVM) *float64 { return &tvm.N })
+	StoreI   = tvmStore("I/YR", func(tvm *TVM) *float64 { return &tvm.I })
+	StorePV  = tvmStore("PV", func(tvm *TVM) *float64 { return &tvm.PV })
+	StorePMT = tvmStore("PMT", func(tvm *TVM) *float64 { return &tvm.PMT })
+	StoreFV  = tvmStore("FV", func(tvm *TVM) *float64 { return &tvm.FV })
+	StorePY  = tvmStore("P/YR", func(tvm *TVM) *float64 { return &tvm.PY })
+)
+
+// SolveN, SolveI, SolvePV, SolvePMT, and SolveFV are Actions with the
+// following description: solve for the register from the other registers; push
+// the result.
+var (
+	SolveN   = tvmSolve("N", func(tvm *TVM) *float64 { return &tvm.N }, (*TVM).solveN)
+	SolveI   = tvmSolve("I/YR", func(tvm *TVM) *float64 { return &tvm.I }, (*TVM).solveI)
+	SolvePV  = tvmSolve("PV", func(tvm *TVM) *float64 { return &tvm.PV }, (*TVM).solvePV)
+	SolvePMT = tvmSolve("PMT", func(tvm *TVM) *float64 { return &tvm.PMT }, (*TVM).solvePMT)
+	SolveFV  = tvmSolve("FV", func(tvm *TVM) *float64 { return &tvm.FV }, (*TVM).solveFV)
+)
+
+// BeginMode is an Action with the following description: make payments at the
+// beginning of each period.
+var BeginMode = &Action{
+	func(so *StackOperator) (string, error) {
+		so.TVM.Begin = true
+		return "payments at beginning of period\n", nil
+	}, 0, 0,
+	"Make payments at the beginning of each period.",
+}
+
+// EndMode is an Action with the following description: make payments at the
+// end of each period.
+var EndMode = &Action{
+	func(so *StackOperator) (string, error) {
+		so.TVM.Begin = false
+		return "payments at end of period\n", nil
+	}, 0, 0,
+	"Make payments at the end of each period.",
+}
+
+// ShowTVM is an Action with the following description: display all time value
+// of money registers.
+var ShowTVM = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.TVM.String(), nil
+	}, 0, 0,
+	"Display all time value of money registers.",
+}
+
+// NPV is an Action with the following description: pop 'a' and all other
+// values as cash flows; push their net present value at 'a' percent per
+// period.
+var NPV = &Action{
+	func(so *StackOperator) (string, error) {
+		rate, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if rate <= -100 {
+			return "", so.Fail("rate must be greater than -100%", Number(rate))
+		}
+		flows, err := floats(so.Stack.Values)
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(rate))
+		}
+		so.Stack.Values = so.Stack.Values[:0]
+		so.Stack.Push(Number(npv(flows, rate/100)))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a' and all other values as cash flows; push their net present value at 'a' percent per period.",
+}
+
+// IRR is an Action with the following description: pop all values as cash
+// flows; push their internal rate of return in percent per period.
+var IRR = &Action{
+	func(so *StackOperator) (string, error) {
+		flows, err := floats(so.Stack.Values)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		var pos, neg bool
+		for _, f := range flows {
+			pos = pos || f > 0
+			neg = neg || f < 0
+		}
+		if !pos || !neg {
+			return "", so.Fail("cash flows need at least one positive and one negative value")
+		}
+		rate, err := findRate(func(i float64) float64 { return npv(flows, i) }, 0.1)
+		if err != nil {
+			return "", so.Fail("no solution for IRR")
+		}
+		so.Stack.Values = so.Stack.Values[:0]
+		so.Stack.Push(Number(rate * 100))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop all values as cash flows; push their internal rate of return in percent per period.",
+}
+
+func npv(flows []float64, i float64) (sum float64) {
+	for k, f := range flows {
+		sum += f / math.Pow(1+i, float64(k))
+	}
+	return sum
+}
+
+// maxPeriods is the most periods an amortization schedule may have: 100 years of
+// monthly payments.
+const maxPeriods = 1200
+
+// Amortize is an Action with the following description: display the
+// amortization schedule of the time value of money registers.
+var Amortize = &Action{
+	func(so *StackOperator) (string, error) {
+		tvm := so.TVM
+		if tvm.N <= 0 || tvm.N != math.Trunc(tvm.N) {
+			return "", so.Fail("N must be a positive integer")
+		}
+		if tvm.N > maxPeriods {
+			return "", so.Fail(fmt.Sprintf("cannot show schedule of more than %d periods", maxPeriods))
+		}
+		i := tvm.rate()
+		sb := new(strings.Builder)
+		sb.WriteString(fmt.Sprintf("%6s %14s %14s %14s\n", "period", "interest", "principal", "balance"))
+		bal := tvm.PV
+		for k := 1; k <= int(tvm.N); k++ {
+			owed := bal
+			if tvm.Begin {
+				owed += tvm.PMT
+			}
+			interest := -owed * i
+			principal := tvm.PMT - interest
+			bal += principal
+			sb.WriteString(fmt.Sprintf("%6d %14.2f %14.2f %14.2f\n", k, interest, principal, bal))
+		}
+		return sb.String(), nil
+	}, 0, 0,
+	"Display the amortization schedule of the time value of money registers.",
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTVMSolve(t *testing.T) {
+	pmt, err := (&TVM{N: 360, I: 6, PV: 200000, PY: 12}).solvePMT()
+	if err != nil || math.Abs(pmt+1199.101050304) > 1e-6 {
+		t.Fatalf("mortgage payment : expected = -1199.101050304 : got = %v, %v", pmt, err)
+	}
+	registers := []struct {
+		name  string
+		reg   func(*TVM) *float64
+		solve func(*TVM) (float64, error)
+	}{
+		{"N", func(tvm *TVM) *float64 { return &tvm.N }, (*TVM).solveN},
+		{"I/YR", func(tvm *TVM) *float64 { return &tvm.I }, (*TVM).solveI},
+		{"PV", func(tvm *TVM) *float64 { return &tvm.PV }, (*TVM).solvePV},
+		{"PMT", func(tvm *TVM) *float64 { return &tvm.PMT }, (*TVM).solvePMT},
+		{"FV", func(tvm *TVM) *float64 { return &tvm.FV }, (*TVM).solveFV},
+	}
+	// Each register solved from the others comes back to what it was.
+	for _, tvm := range []TVM{
+		{N: 360, I: 6, PV: 200000, PMT: pmt, PY: 12},
+		{N: 10, I: 5, PV: -1000, PMT: -100, FV: 2886.68, PY: 1, Begin: true},
+		{N: 24, I: 0, PV: 1200, PMT: -50, PY: 12},
+	} {
+		// Make the registers balance exactly before solving for them.
+		tvm.FV, _ = tvm.solveFV()
+		for _, r := range registers {
+			if r.name == "I/YR" && tvm.I == 0 {
+				continue
+			}
+			want := *r.reg(&tvm)
+			got, err := r.solve(&tvm)
+			if err != nil {
+				t.Fatalf("%+v : solving %s : unexpected error %q", tvm, r.name, err)
+			}
+			if math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+				t.Fatalf("%+v : solving %s : expected = %v : got = %v", tvm, r.name, want, got)
+			}
+		}
+	}
+}
+
+func TestTVMErrors(t *testing.T) {
+	if _, err := (&TVM{PV: 100, PY: 12}).solvePMT(); err == nil {
+		t.Fatal("expected error solving for PMT with N = 0")
+	}
+	if _, err := (&TVM{PV: 100, FV: -200, PY: 12}).solveN(); err == nil {
+		t.Fatal("expected error solving for N with no interest or payments")
+	}
+	if _, err := (&TVM{I: 5, PV: 100, FV: 100, PY: 12}).solveN(); err == nil {
+		t.Fatal("expected error solving for N that does not exist")
+	}
+	if _, err := (&TVM{N: 10, PV: 100, FV: 100, PY: 12}).solveI(); err == nil {
+		t.Fatal("expected error solving for I/YR that does not exist")
+	}
+}
+
+func TestNPV(t *testing.T) {
+	flows := []float64{-100, 60, 60}
+	if got, want := npv(flows, 0.1), -100+60/1.1+60/1.21; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("npv : expected = %v : got = %v", want, got)
+	}
+	irr, err := findRate(func(i float64) float64 { return npv(flows, i) }, 0.1)
+	if err != nil || math.Abs(npv(flows, irr)) > 1e-9 {
+		t.Fatalf("irr : got = %v, %v", irr, err)
+	}
+}
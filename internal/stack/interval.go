@@ -0,0 +1,410 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Interval is a range of real numbers, written like [1,1.1]. Arithmetic on
+// intervals rounds outward, so the true result of any calculation on numbers
+// inside the intervals is always inside the resulting interval.
+type Interval struct {
+	Lo, Hi float64
+}
+
+func (iv Interval) String() string { return fmt.Sprintf("[%v,%v]", iv.Lo, iv.Hi) }
+
+func (Interval) kind() string { return "interval" }
+
+func (iv Interval) format(prec int) string {
+	return fmt.Sprintf("[%.*g,%.*g]", prec, iv.Lo, prec, iv.Hi)
+}
+
+func (iv Interval) contains(x float64) bool { return iv.Lo <= x && x <= iv.Hi }
+
+// down returns x, which was rounded from a true value of x+e, rounded toward
+// negative infinity instead.
+func down(x, e float64) float64 {
+	if e < 0 {
+		return math.Nextafter(x, math.Inf(-1))
+	}
+	return x
+}
+
+// up returns x, which was rounded from a true value of x+e, rounded toward
+// positive infinity instead.
+func up(x, e float64) float64 {
+	if e > 0 {
+		return math.Nextafter(x, math.Inf(1))
+	}
+	return x
+}
+
+// The following return the rounded result of an operation and the error e of
+// rounding, so that the true result is the rounded result plus e.
+
+func sumErr(a, b float64) (s, e float64) {
+	s = a + b
+	bb := s - a
+	e = (a - (s - bb)) + (b - bb)
+	if math.IsNaN(e) {
+		e = 0
+	}
+	return s, e
+}
+
+func mulErr(a, b float64) (p, e float64) {
+	p = a * b
+	e = math.FMA(a, b, -p)
+	if math.IsNaN(e) {
+		e = 0
+	}
+	return p, e
+}
+
+func divErr(a, b float64) (q, e float64) {
+	q = a / b
+	// r is exactly a - q*b, so the true quotient is q + r/b.
+	r := math.FMA(-q, b, a)
+	if math.IsNaN(r) || math.IsInf(q, 0) {
+		return q, 0
+	}
+	return q, r / b
+}
+
+// exact reports whether y = f(x) is known to be exact even though f is not
+// correctly rounded. The math package gets the integer results of its
+// functions at 0 and 1, like sin(0) and ln(1), exactly right.
+func exact(x, y float64) bool {
+	return (x == 0 || x == 1) && y == math.Trunc(y)
+}
+
+// slack is how many units in the last place widenDown and widenUp move a
+// result. The math package does not promise how accurate its functions are;
+// its own tests allow errors of a few units in the last place for functions
+// like Pow, Exp, Sin and Gamma, so leave room for more than that.
+const slack = 4
+
+// widen returns y moved n units in the last place toward dir.
+func widen(y, dir float64, n int) float64 {
+	for range n {
+		y = math.Nextafter(y, dir)
+	}
+	return y
+}
+
+// widenDown returns y = f(x) moved down by slack units in the last place, for
+// functions f that are not correctly rounded.
+func widenDown(x, y float64) float64 {
+	if exact(x, y) {
+		return y
+	}
+	return widen(y, math.Inf(-1), slack)
+}
+
+// widenUp returns y = f(x) moved up by slack units in the last place, for
+// functions f that are not correctly rounded.
+func widenUp(x, y float64) float64 {
+	if exact(x, y) {
+		return y
+	}
+	return widen(y, math.Inf(1), slack)
+}
+
+// corners returns the interval spanning f applied to every combination of the
+// ends of a and b, with f rounding down for the low end and up for the high end.
+func corners(a, b Interval, f func(x, y float64) (float64, float64)) Interval {
+	res := Interval{math.Inf(1), math.Inf(-1)}
+	for _, x := range []float64{a.Lo, a.Hi} {
+		for _, y := range []float64{b.Lo, b.Hi} {
+			z, e := f(x, y)
+			res.Lo = math.Min(res.Lo, down(z, e))
+			res.Hi = math.Max(res.Hi, up(z, e))
+		}
+	}
+	return res
+}
+
+// powInt returns iv^n for an integer n.
+func (iv Interval) powInt(n int) (Interval, error) {
+	if n < 0 {
+		p, err := iv.powInt(-n)
+		if err != nil {
+			return Interval{}, err
+		}
+		return Interval{1, 1}.div(p)
+	}
+	// pow returns x^n rounded down and up for x >= 0.
+	pow := func(x float64) (lo, hi float64) {
+		lo, hi = 1, 1
+		for i := 0; i < n; i++ {
+			p, e := mulErr(lo, x)
+			lo = down(p, e)
+			p, e = mulErr(hi, x)
+			hi = up(p, e)
+		}
+		return lo, hi
+	}
+	switch {
+	case iv.Lo >= 0:
+		lo, _ := pow(iv.Lo)
+		_, hi := pow(iv.Hi)
+		return Interval{lo, hi}, nil
+	case n%2 == 0 && iv.Hi <= 0:
+		lo, _ := pow(-iv.Hi)
+		_, hi := pow(-iv.Lo)
+		return Interval{lo, hi}, nil
+	case n%2 == 0:
+		_, hi := pow(math.Max(-iv.Lo, iv.Hi))
+		return Interval{0, hi}, nil
+	}
+	_, lo := pow(-iv.Lo)
+	if iv.Hi < 0 {
+		hi, _ := pow(-iv.Hi)
+		return Interval{-lo, -hi}, nil
+	}
+	_, hi := pow(iv.Hi)
+	return Interval{-lo, hi}, nil
+}
+
+func (iv Interval) div(b Interval) (Interval, error) {
+	if b.contains(0) {
+		return Interval{}, errors.New("cannot divide by interval containing 0")
+	}
+	return corners(iv, b, divErr), nil
+}
+
+// mod returns the remainder of dividing iv by b, which must be a single number.
+// math.Mod is exact, so no rounding is needed.
+func (iv Interval) mod(b Interval) (Interval, error) {
+	if b.Lo != b.Hi {
+		return Interval{}, errors.New("cannot take remainder of dividing by interval")
+	}
+	if b.Lo == 0 {
+		return Interval{}, errors.New("cannot divide by 0")
+	}
+	m := math.Abs(b.Lo)
+	lo, hi := math.Mod(iv.Lo, m), math.Mod(iv.Hi, m)
+	if (iv.Lo >= 0 || iv.Hi <= 0) && lo <= hi && iv.Hi-iv.Lo < m {
+		return Interval{lo, hi}, nil
+	}
+	// The remainder wraps around somewhere inside iv.
+	res := Interval{math.Min(iv.Lo, 0), math.Max(iv.Hi, 0)}
+	res.Lo, res.Hi = math.Max(res.Lo, -m), math.Min(res.Hi, m)
+	return res, nil
+}
+
+func (iv Interval) arith(op byte, y Value, reversed bool) (Value, error) {
+	var b Interval
+	switch y := y.(type) {
+	case Interval:
+		b = y
+	default:
+		f, ok := asFloat(y)
+		if !ok {
+			return nil, errUnsupported
+		}
+		b = Interval{f, f}
+	}
+	a := iv
+	if reversed {
+		a, b = b, a
+	}
+	switch op {
+	case '+':
+		lo, e := sumErr(a.Lo, b.Lo)
+		hi, f := sumErr(a.Hi, b.Hi)
+		return Interval{down(lo, e), up(hi, f)}, nil
+	case '-':
+		lo, e := sumErr(a.Lo, -b.Hi)
+		hi, f := sumErr(a.Hi, -b.Lo)
+		return Interval{down(lo, e), up(hi, f)}, nil
+	case '*':
+		return corners(a, b, mulErr), nil
+	case '/':
+		return a.div(b)
+	case '%':
+		return a.mod(b)
+	case '^':
+		if b.Lo == b.Hi && b.Lo == math.Trunc(b.Lo) && math.Abs(b.Lo) <= 1024 {
+			return a.powInt(int(b.Lo))
+		}
+		if a.Lo <= 0 {
+			return nil, errors.New("cannot raise interval containing non-positive numbers to non-integer power")
+		}
+		res := Interval{math.Inf(1), math.Inf(-1)}
+		for _, x := range []float64{a.Lo, a.Hi} {
+			for _, y := range []float64{b.Lo, b.Hi} {
+				z := math.Pow(x, y)
+				res.Lo = math.Min(res.Lo, widenDown(x, z))
+				res.Hi = math.Max(res.Hi, widenUp(x, z))
+			}
+		}
+		return res, nil
+	}
+	return nil, errUnsupported
+}
+
+// monotonicity records the functions that are increasing (1) or decreasing
+// (-1) everywhere they are defined.
+var monotonicity = map[string]int{
+	"logarithm":                    1,
+	"degrees":                      1,
+	"radians":                      1,
+	"arcsine":                      1,
+	"arccosine":                    -1,
+	"arctangent":                   1,
+	"floor":                        1,
+	"ceiling":                      1,
+	"round":                        1,
+	"hyperbolic sine":              1,
+	"hyperbolic tangent":           1,
+	"hyperbolic arcsine":           1,
+	"hyperbolic arccosine":         1,
+	"hyperbolic arctangent":        1,
+	"error function":               1,
+	"complementary error function": -1,
+}
+
+// gammaMin is where the gamma function has its minimum for positive numbers.
+const gammaMin = 1.4616321449683623
+
+func (iv Interval) apply(fn function) (Value, error) {
+	dir, pres := monotonicity[fn.name]
+	switch {
+	case pres:
+	case fn.name == "hyperbolic cosine":
+		near, far := math.Abs(iv.Lo), math.Abs(iv.Hi)
+		if near > far {
+			near, far = far, near
+		}
+		if iv.contains(0) {
+			near = 0
+		}
+		return Interval{widenDown(near, math.Cosh(near)), widenUp(far, math.Cosh(far))}, nil
+	case fn.name == "gamma" && iv.Lo >= gammaMin, fn.name == "factorial" && iv.Lo >= gammaMin-1:
+		dir = 1
+	default:
+		return nil, fmt.Errorf("cannot take %s of interval", fn.name)
+	}
+	lo, err := fn.call(iv.Lo)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := fn.call(iv.Hi)
+	if err != nil {
+		return nil, err
+	}
+	if fn.keepsUnits {
+		// Rounding functions are exact.
+		if dir < 0 {
+			lo, hi = hi, lo
+		}
+		return Interval{lo, hi}, nil
+	}
+	if dir < 0 {
+		return Interval{widenDown(iv.Hi, hi), widenUp(iv.Lo, lo)}, nil
+	}
+	return Interval{widenDown(iv.Lo, lo), widenUp(iv.Hi, hi)}, nil
+}
+
+// trig returns the interval spanning fn, a trigonometric function taking
+// angles in m, over iv.
+func (iv Interval) trig(fn function, m AngleMode) (Value, error) {
+	q := m.quarterTurn()
+	f := m.forward(fn)
+	// hits reports whether iv contains c plus a multiple of 4q.
+	hits := func(c float64) bool {
+		return math.Ceil((iv.Lo-c)/(4*q)) <= math.Floor((iv.Hi-c)/(4*q))
+	}
+	lo, err := f.call(iv.Lo)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := f.call(iv.Hi)
+	if err != nil {
+		return nil, err
+	}
+	// Outside of radian mode, whole numbers of right angles are exact, so
+	// treat them like 0.
+	at := func(x float64) float64 {
+		if m != RadianMode && math.Mod(x, q) == 0 {
+			return 0
+		}
+		return x
+	}
+	if fn.poles {
+		if hits(q) || hits(-q) {
+			return nil, fmt.Errorf("cannot take %s of interval containing asymptote", fn.name)
+		}
+		return Interval{widenDown(at(iv.Lo), lo), widenUp(at(iv.Hi), hi)}, nil
+	}
+	var top, bottom float64
+	switch fn.name {
+	case "sine":
+		top, bottom = q, -q
+	case "cosine":
+		top, bottom = 0, 2*q
+	default:
+		return nil, fmt.Errorf("cannot take %s of interval", fn.name)
+	}
+	res := Interval{
+		math.Min(widenDown(at(iv.Lo), lo), widenDown(at(iv.Hi), hi)),
+		math.Max(widenUp(at(iv.Lo), lo), widenUp(at(iv.Hi), hi)),
+	}
+	if hits(top) || iv.Hi-iv.Lo >= 4*q {
+		res.Hi = 1
+	}
+	if hits(bottom) || iv.Hi-iv.Lo >= 4*q {
+		res.Lo = -1
+	}
+	res.Lo, res.Hi = math.Max(res.Lo, -1), math.Min(res.Hi, 1)
+	return res, nil
+}
+
+// parseEnd parses s, one end of an interval, rounded toward negative infinity
+// if down is true and positive infinity if not, so that the interval contains
+// the decimal number written even if it is not exactly a float.
+func parseEnd(s string, down bool) (float64, error) {
+	s = strings.TrimSpace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number", s)
+	}
+	exact, ok := new(big.Rat).SetString(s)
+	if !ok || math.IsInf(f, 0) {
+		return f, nil
+	}
+	switch c := new(big.Rat).SetFloat64(f).Cmp(exact); {
+	case down && c > 0:
+		return math.Nextafter(f, math.Inf(-1)), nil
+	case !down && c < 0:
+		return math.Nextafter(f, math.Inf(1)), nil
+	}
+	return f, nil
+}
+
+// parseInterval parses the inside of an interval like [1,1.1] without the
+// brackets. The ends are rounded outward.
+func parseInterval(s string) (Interval, error) {
+	l, h, _ := strings.Cut(s, ",")
+	lo, err := parseEnd(l, true)
+	if err != nil {
+		return Interval{}, err
+	}
+	hi, err := parseEnd(h, false)
+	if err != nil {
+		return Interval{}, err
+	}
+	if lo > hi {
+		return Interval{}, errors.New("low end of interval is greater than high end")
+	}
+	return Interval{lo, hi}, nil
+}
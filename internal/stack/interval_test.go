@@ -0,0 +1,115 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// ratContains reports whether iv contains the decimal number s exactly.
+func ratContains(iv Interval, s string) bool {
+	r, _ := new(big.Rat).SetString(s)
+	return new(big.Rat).SetFloat64(iv.Lo).Cmp(r) <= 0 && new(big.Rat).SetFloat64(iv.Hi).Cmp(r) >= 0
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Interval
+		inexact bool
+	}{
+		{"1,2", Interval{1, 2}, false},
+		{"-0.5,0.25", Interval{-0.5, 0.25}, false},
+		{"0.1,0.1", Interval{}, true},
+		{"-0.3,1e-7", Interval{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseInterval(test.s)
+		if err != nil {
+			t.Fatalf("%s : unexpected error %q", test.s, err)
+		}
+		if !test.inexact {
+			if got != test.want {
+				t.Fatalf("%s : expected = %v : got = %v", test.s, test.want, got)
+			}
+			continue
+		}
+		lo, hi, _ := strings.Cut(test.s, ",")
+		if !ratContains(got, lo) || !ratContains(got, hi) {
+			t.Fatalf("%s : %v does not contain its ends", test.s, got)
+		}
+		if got.Lo == got.Hi {
+			t.Fatalf("%s : %v was not rounded outward", test.s, got)
+		}
+	}
+	if _, err := parseInterval("2,1"); err == nil {
+		t.Fatal("2,1 : expected error")
+	}
+}
+
+func TestIntervalArith(t *testing.T) {
+	tenth, _ := parseInterval("0.1,0.1")
+	tests := []struct {
+		op   byte
+		y    Value
+		want string
+	}{
+		{'+', Interval{0.2, 0.2}, "0.3"},
+		{'*', Number(3), "0.3"},
+		{'-', Number(1), "-0.9"},
+	}
+	for _, test := range tests {
+		v, err := tenth.arith(test.op, test.y, false)
+		if err != nil {
+			t.Fatalf("0.1 %c %v : unexpected error %q", test.op, test.y, err)
+		}
+		if !ratContains(v.(Interval), test.want) {
+			t.Fatalf("0.1 %c %v : %v does not contain %s", test.op, test.y, v, test.want)
+		}
+	}
+}
+
+func TestIntervalWiden(t *testing.T) {
+	y := math.Exp(0.5)
+	lo, hi := widenDown(0.5, y), widenUp(0.5, y)
+	for n := 0; n < slack; n++ {
+		y0 := y
+		if lo = math.Nextafter(lo, math.Inf(1)); lo > y0 {
+			t.Fatalf("widenDown moved %v fewer than %d ulps", y, slack)
+		}
+		if hi = math.Nextafter(hi, math.Inf(-1)); hi < y0 {
+			t.Fatalf("widenUp moved %v fewer than %d ulps", y, slack)
+		}
+	}
+	if lo != y || hi != y {
+		t.Fatalf("widening %v by %d ulps : got = [%v,%v]", y, slack, widenDown(0.5, y), widenUp(0.5, y))
+	}
+	// Integer results at 0 and 1 are exact and are not widened.
+	if widenDown(0, 1) != 1 || widenUp(1, 0) != 0 {
+		t.Fatal("exact results were widened")
+	}
+}
+
+func TestIntervalApply(t *testing.T) {
+	v, err := Interval{0.5, 2}.apply(ln)
+	if err != nil {
+		t.Fatalf("[0.5,2] ln : unexpected error %q", err)
+	}
+	if got := v.(Interval); !(got.Lo < math.Log(0.5) && math.Log(2) < got.Hi) {
+		t.Fatalf("[0.5,2] ln : got = %v", got)
+	}
+	v, err = Interval{-1, 1}.apply(cosh)
+	if err != nil {
+		t.Fatalf("[-1,1] cosh : unexpected error %q", err)
+	}
+	if got := v.(Interval); got.Lo != 1 || !(math.Cosh(1) < got.Hi) {
+		t.Fatalf("[-1,1] cosh : got = %v", got)
+	}
+	if _, err := (Interval{-1, 1}).apply(function{"unknown", math.Sin, nil, false, nil, false}); err == nil {
+		t.Fatal("expected error applying unknown function to interval")
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Vector is a list of real numbers, written like [1 2 3].
+type Vector []float64
+
+func (v Vector) String() string { return v.format(-1) }
+
+func (Vector) kind() string { return "vector" }
+
+func (v Vector) format(prec int) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(x, 'g', prec, 64)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func (v Vector) arith(op byte, y Value, reversed bool) (Value, error) {
+	switch y := y.(type) {
+	case Vector:
+		if len(v) != len(y) {
+			return nil, fmt.Errorf("cannot %s vectors of length %d and %d", opNames[op], len(v), len(y))
+		}
+		return elementwise(op, v, func(i int) float64 { return y[i] }, reversed)
+	}
+	if f, ok := asFloat(y); ok {
+		return elementwise(op, v, func(int) float64 { return f }, reversed)
+	}
+	return nil, errUnsupported
+}
+
+func (v Vector) apply(fn function) (Value, error) {
+	w := make(Vector, len(v))
+	for i, x := range v {
+		y, err := fn.call(x)
+		if err != nil {
+			return nil, err
+		}
+		w[i] = y
+	}
+	return w, nil
+}
+
+// Matrix is a rectangular grid of real numbers stored as a list of rows,
+// written like [[1 2] [3 4]].
+type Matrix [][]float64
+
+func newMatrix(rows, cols int) Matrix {
+	m := make(Matrix, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+	}
+	return m
+}
+
+func identity(n int) Matrix {
+	m := newMatrix(n, n)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+func (m Matrix) String() string { return m.format(-1) }
+
+func (Matrix) kind() string { return "matrix" }
+
+func (m Matrix) format(prec int) string {
+	rows := make([]string, len(m))
+	for i, row := range m {
+		rows[i] = Vector(row).format(prec)
+	}
+	return "[" + strings.Join(rows, " ") + "]"
+}
+
+func (m Matrix) cols() int { return len(m[0]) }
+
+func (m Matrix) arith(op byte, y Value, reversed bool) (Value, error) {
+	var at func(i, j int) float64
+	switch y := y.(type) {
+	case Matrix:
+		if len(m) != len(y) || m.cols() != y.cols() {
+			return nil, fmt.Errorf("cannot %s %dx%d and %dx%d matrices", opNames[op], len(m), m.cols(), len(y), y.cols())
+		}
+		at = func(i, j int) float64 { return y[i][j] }
+	default:
+		f, ok := asFloat(y)
+		if !ok {
+			return nil, errUnsupported
+		}
+		at = func(int, int) float64 { return f }
+	}
+	n := make(Matrix, len(m))
+	for i, row := range m {
+		v, err := elementwise(op, row, func(j int) float64 { return at(i, j) }, reversed)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v
+	}
+	return n, nil
+}
+
+func (m Matrix) apply(fn function) (Value, error) {
+	n := make(Matrix, len(m))
+	for i, row := range m {
+		v, err := Vector(row).apply(fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v.(Vector)
+	}
+	return n, nil
+}
+
+func (m Matrix) transpose() Matrix {
+	t := newMatrix(m.cols(), len(m))
+	for i, row := range m {
+		for j, x := range row {
+			t[j][i] = x
+		}
+	}
+	return t
+}
+
+// mul returns the matrix product of m and n.
+func (m Matrix) mul(n Matrix) (Matrix, error) {
+	if m.cols() != len(n) {
+		return nil, fmt.Errorf("cannot multiply %dx%d and %dx%d matrices", len(m), m.cols(), len(n), n.cols())
+	}
+	p := newMatrix(len(m), n.cols())
+	for i := range p {
+		for j := range p[i] {
+			for k, x := range m[i] {
+				p[i][j] += x * n[k][j]
+			}
+		}
+	}
+	return p, nil
+}
+
+// lu is the LU decomposition of a square matrix with partial pivoting.
+type lu struct {
+	a    Matrix
+	perm []int
+	sign float64
+}
+
+var errSingular = errors.New("matrix is singular")
+
+// decompose returns the LU decomposition of m, or errSingular if m has no
+// inverse.
+func (m Matrix) decompose() (*lu, error) {
+	n := len(m)
+	if n != m.cols() {
+		return nil, fmt.Errorf("matrix is not square (%dx%d)", n, m.cols())
+	}
+	a := make(Matrix, n)
+	perm := make([]int, n)
+	for i, row := range m {
+		a[i] = append([]float64(nil), row...)
+		perm[i] = i
+	}
+	sign := 1.0
+	for k := 0; k < n; k++ {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i][k]) > math.Abs(a[p][k]) {
+				p = i
+			}
+		}
+		if a[p][k] == 0 {
+			return nil, errSingular
+		}
+		if p != k {
+			a[p], a[k] = a[k], a[p]
+			perm[p], perm[k] = perm[k], perm[p]
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			a[i][k] /= a[k][k]
+			for j := k + 1; j < n; j++ {
+				a[i][j] -= a[i][k] * a[k][j]
+			}
+		}
+	}
+	return &lu{a, perm, sign}, nil
+}
+
+func (d *lu) det() float64 {
+	det := d.sign
+	for i := range d.a {
+		det *= d.a[i][i]
+	}
+	return det
+}
+
+// solve returns x such that the decomposed matrix times x equals b.
+func (d *lu) solve(b []float64) Vector {
+	n := len(d.a)
+	x := make(Vector, n)
+	for i := 0; i < n; i++ {
+		x[i] = b[d.perm[i]]
+		for j := 0; j < i; j++ {
+			x[i] -= d.a[i][j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= d.a[i][j] * x[j]
+		}
+		x[i] /= d.a[i][i]
+	}
+	return x
+}
+
+func (d *lu) inverse() Matrix {
+	n := len(d.a)
+	inv := newMatrix(n, n)
+	id := identity(n)
+	for j := 0; j < n; j++ {
+		col := d.solve(id[j])
+		for i := range col {
+			inv[i][j] = col[i]
+		}
+	}
+	return inv
+}
+
+// elementwise returns the result of 'xs[i] op y(i)' for every element of xs,
+// or 'y(i) op xs[i]' if reversed is true.
+func elementwise(op byte, xs []float64, y func(i int) float64, reversed bool) (Vector, error) {
+	zs := make(Vector, len(xs))
+	for i, x := range xs {
+		a, b := x, y(i)
+		if reversed {
+			a, b = b, a
+		}
+		z, err := floatArith(op, a, b)
+		if err != nil {
+			return nil, err
+		}
+		zs[i] = z
+	}
+	return zs, nil
+}
+
+// splitInput splits input into tokens on spaces, keeping everything between
+// matching square brackets together as one token.
+func splitInput(input string) ([]string, error) {
+	tokens := make([]string, 0)
+	depth := 0
+	for _, s := range strings.Split(input, " ") {
+		if depth > 0 {
+			tokens[len(tokens)-1] += " " + s
+		} else {
+			tokens = append(tokens, s)
+		}
+		depth += strings.Count(s, "[") - strings.Count(s, "]")
+		if depth < 0 {
+			return nil, errors.New(fmt.Sprintf("unexpected ] in %s\n", tokens[len(tokens)-1]))
+		}
+	}
+	if depth > 0 {
+		return nil, errors.New(fmt.Sprintf("missing ] in %s\n", tokens[len(tokens)-1]))
+	}
+	return tokens, nil
+}
+
+// parseArray parses a vector like [1 2 3], a matrix like [[1 2] [3 4]], or an
+// interval like [1,1.1].
+func parseArray(token string) (Value, error) {
+	inner, ok := strings.CutPrefix(token, "[")
+	if inner, ok = strings.CutSuffix(inner, "]"); !ok {
+		return nil, errors.New("missing ]")
+	}
+	inner = strings.TrimSpace(inner)
+	if !strings.HasPrefix(inner, "[") {
+		if strings.Contains(inner, ",") {
+			return parseInterval(inner)
+		}
+		return parseVector(inner)
+	}
+	m := make(Matrix, 0)
+	for inner != "" {
+		end := strings.Index(inner, "]")
+		if !strings.HasPrefix(inner, "[") || end < 0 {
+			return nil, errors.New("matrix rows must look like [1 2]")
+		}
+		row, err := parseVector(inner[1:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(m) > 0 && len(row) != m.cols() {
+			return nil, fmt.Errorf("row %d has length %d, expected %d", len(m)+1, len(row), m.cols())
+		}
+		m = append(m, row)
+		inner = strings.TrimSpace(inner[end+1:])
+	}
+	return m, nil
+}
+
+func parseVector(s string) (Vector, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("vectors cannot be empty")
+	}
+	v := make(Vector, len(fields))
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", field)
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+// popMatrix pops 'a', which must be a matrix. The stack is left untouched if it
+// is not.
+func (so *StackOperator) popMatrix() (Matrix, error) {
+	v := so.Stack.Values[len(so.Stack.Values)-1]
+	m, ok := v.(Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected matrix, got %s %v", v.kind(), v)
+	}
+	so.Stack.Pop()
+	return m, nil
+}
+
+// solveLinear pops 'a', 'b' and pushes the vector x such that 'b' times x
+// equals 'a'.
+func (so *StackOperator) solveLinear() (string, error) {
+	b := so.Stack.Pop().(Vector)
+	a := so.Stack.Pop().(Matrix)
+	if len(a) != len(b) {
+		return "", so.Fail(fmt.Sprintf("cannot solve %dx%d system with vector of length %d", len(a), a.cols(), len(b)), a, b)
+	}
+	d, err := a.decompose()
+	if err != nil {
+		return "", so.Fail(err.Error(), a, b)
+	}
+	so.Stack.Push(d.solve(b))
+	return so.Stack.Display(), nil
+}
+
+// Dot is an Action with the following description: pop 'a', 'b'; push the dot
+// product of two vectors, or the matrix product of 'b' and 'a'.
+var Dot = &Action{
+	func(so *StackOperator) (string, error) {
+		y := so.Stack.Pop()
+		x := so.Stack.Pop()
+		var z Value
+		var err error
+		switch a := x.(type) {
+		case Vector:
+			switch b := y.(type) {
+			case Vector:
+				if len(a) != len(b) {
+					err = fmt.Errorf("cannot take dot product of vectors of length %d and %d", len(a), len(b))
+					break
+				}
+				var sum float64
+				for i := range a {
+					sum += a[i] * b[i]
+				}
+				z = Number(sum)
+			case Matrix:
+				var p Matrix
+				if p, err = (Matrix{a}).mul(b); err == nil {
+					z = Vector(p[0])
+				}
+			}
+		case Matrix:
+			switch b := y.(type) {
+			case Vector:
+				var p Matrix
+				if p, err = a.mul(Matrix{b}.transpose()); err == nil {
+					z = Vector(p.transpose()[0])
+				}
+			case Matrix:
+				z, err = a.mul(b)
+			}
+		}
+		if err == nil && z == nil {
+			err = fmt.Errorf("cannot take dot product of %s and %s", x.kind(), y.kind())
+		}
+		if err != nil {
+			return "", so.Fail(err.Error(), x, y)
+		}
+		so.Stack.Push(z)
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push the dot product of vectors 'b' and 'a', or the matrix product of 'b' and 'a'.",
+}
+
+// Cross is an Action with the following description: pop 'a', 'b'; push the
+// cross product of 'b' and 'a'.
+var Cross = &Action{
+	func(so *StackOperator) (string, error) {
+		y := so.Stack.Pop()
+		x := so.Stack.Pop()
+		a, aOk := x.(Vector)
+		b, bOk := y.(Vector)
+		if !aOk || !bOk || len(a) != 3 || len(b) != 3 {
+			return "", so.Fail("cross product needs two vectors of length 3", x, y)
+		}
+		so.Stack.Push(Vector{
+			a[1]*b[2] - a[2]*b[1],
+			a[2]*b[0] - a[0]*b[2],
+			a[0]*b[1] - a[1]*b[0],
+		})
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push the cross product of vectors 'b' and 'a'.",
+}
+
+// Det is an Action with the following description: pop 'a'; push the
+// determinant of 'a'.
+var Det = &Action{
+	func(so *StackOperator) (string, error) {
+		m, err := so.popMatrix()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		d, err := m.decompose()
+		switch {
+		case err == errSingular:
+			so.Stack.Push(Number(0))
+		case err != nil:
+			return "", so.Fail(err.Error(), m)
+		default:
+			so.Stack.Push(Number(d.det()))
+		}
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the determinant of matrix 'a'.",
+}
+
+// Inv is an Action with the following description: pop 'a'; push the inverse
+// of 'a'.
+var Inv = &Action{
+	func(so *StackOperator) (string, error) {
+		m, err := so.popMatrix()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		d, err := m.decompose()
+		if err != nil {
+			return "", so.Fail(err.Error(), m)
+		}
+		so.Stack.Push(d.inverse())
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the inverse of matrix 'a'.",
+}
+
+// Transpose is an Action with the following description: pop 'a'; push the
+// transpose of 'a'.
+var Transpose = &Action{
+	func(so *StackOperator) (string, error) {
+		switch v := so.Stack.Pop().(type) {
+		case Matrix:
+			so.Stack.Push(v.transpose())
+		case Vector:
+			so.Stack.Push(Matrix{v}.transpose())
+		default:
+			return "", so.Fail(fmt.Sprintf("cannot transpose %s", v.kind()), v)
+		}
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the transpose of matrix 'a'. Vectors become column matrices.",
+}
+
+// Norm is an Action with the following description: pop 'a'; push the
+// Euclidean norm of 'a'.
+var Norm = &Action{
+	func(so *StackOperator) (string, error) {
+		v := so.Stack.Pop()
+		var xs []float64
+		switch v := v.(type) {
+		case Vector:
+			xs = v
+		case Matrix:
+			for _, row := range v {
+				xs = append(xs, row...)
+			}
+		default:
+			f, ok := asFloat(v)
+			if !ok {
+				return "", so.Fail(fmt.Sprintf("cannot take norm of %s", v.kind()), v)
+			}
+			xs = []float64{f}
+		}
+		var sum float64
+		for _, x := range xs {
+			sum += x * x
+		}
+		so.Stack.Push(Number(math.Sqrt(sum)))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the Euclidean norm of vector or matrix 'a'.",
+}
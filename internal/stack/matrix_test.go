@@ -0,0 +1,115 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDecompose(t *testing.T) {
+	tests := []struct {
+		m   Matrix
+		det float64
+	}{
+		{Matrix{{2, 0}, {0, 3}}, 6},
+		// The first pivot is 0, so rows must be swapped.
+		{Matrix{{0, 1}, {1, 0}}, -1},
+		{Matrix{{1, 2, 3}, {0, 1, 4}, {5, 6, 0}}, 1},
+	}
+	for _, test := range tests {
+		d, err := test.m.decompose()
+		if err != nil {
+			t.Fatalf("%v : unexpected error %q", test.m, err)
+		}
+		if det := d.det(); math.Abs(det-test.det) > 1e-12 {
+			t.Fatalf("%v det : expected = %v : got = %v", test.m, test.det, det)
+		}
+		p, _ := test.m.mul(d.inverse())
+		for i, row := range p {
+			for j, x := range row {
+				if math.Abs(x-identity(len(p))[i][j]) > 1e-12 {
+					t.Fatalf("%v times its inverse : got = %v", test.m, p)
+				}
+			}
+		}
+		b := make([]float64, len(test.m))
+		for i := range b {
+			b[i] = float64(i + 1)
+		}
+		x := d.solve(b)
+		for i, row := range test.m {
+			var sum float64
+			for j, c := range row {
+				sum += c * x[j]
+			}
+			if math.Abs(sum-b[i]) > 1e-12 {
+				t.Fatalf("%v x = %v : got x = %v", test.m, b, x)
+			}
+		}
+	}
+	if _, err := (Matrix{{1, 2}, {2, 4}}).decompose(); !errors.Is(err, errSingular) {
+		t.Fatalf("expected singular matrix error : got = %v", err)
+	}
+	if _, err := (Matrix{{1, 2, 3}, {4, 5, 6}}).decompose(); err == nil {
+		t.Fatal("expected error decomposing matrix that is not square")
+	}
+}
+
+func TestMatrixMul(t *testing.T) {
+	a := Matrix{{1, 2, 3}, {4, 5, 6}}
+	p, err := a.mul(a.transpose())
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	if want := (Matrix{{14, 32}, {32, 77}}); !slices.EqualFunc(p, want, slices.Equal) {
+		t.Fatalf("expected = %v : got = %v", want, p)
+	}
+	if _, err := a.mul(a); err == nil {
+		t.Fatal("expected error multiplying 2x3 and 2x3 matrices")
+	}
+	if _, err := a.arith('+', a.transpose(), false); err == nil {
+		t.Fatal("expected error adding 2x3 and 3x2 matrices")
+	}
+}
+
+func TestParseArray(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Value
+	}{
+		{"[1 2 3]", Vector{1, 2, 3}},
+		{"[ -1.5 ]", Vector{-1.5}},
+		{"[[1 2] [3 4]]", Matrix{{1, 2}, {3, 4}}},
+		{"[1,2]", Interval{1, 2}},
+	}
+	for _, test := range tests {
+		got, err := parseArray(test.s)
+		if err != nil {
+			t.Fatalf("%s : unexpected error %q", test.s, err)
+		}
+		if got.String() != test.want.String() {
+			t.Fatalf("%s : expected = %v : got = %v", test.s, test.want, got)
+		}
+	}
+	for _, s := range []string{"[]", "[1 x]", "[[1 2] [3]]", "[[1 2] 3]", "[1 2"} {
+		if _, err := parseArray(s); err == nil {
+			t.Fatalf("%s : expected error", s)
+		}
+	}
+}
+
+func TestSplitInput(t *testing.T) {
+	got, err := splitInput("[[1 2] [3 4]] [5 6] +")
+	if want := []string{"[[1 2] [3 4]]", "[5 6]", "+"}; err != nil || !slices.Equal(got, want) {
+		t.Fatalf("expected = %q : got = %q, %v", want, got, err)
+	}
+	for _, s := range []string{"[1 2", "1 2]"} {
+		if _, err := splitInput(s); err == nil {
+			t.Fatalf("%s : expected error", s)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Defaults for the settings used by the numerical Actions.
+const (
+	defTolerance  = 1e-12
+	defIterations = 100
+	// maxDepth is how many times integrate may halve an interval, and minDepth
+	// how many times it must, so that it does not miss features narrower than
+	// the whole interval.
+	maxDepth = 50
+	minDepth = 5
+	// evalsPerIteration is how many times integrate may evaluate the function
+	// for each iteration it is allowed.
+	evalsPerIteration = 100
+	// maxEvalDepth is how deeply evaluating a word may nest, so that a word
+	// that references itself fails instead of running out of stack.
+	maxEvalDepth = 64
+)
+
+// WordRef is a reference to a word or operator, written with a leading ', that
+// can be passed to Actions which evaluate it as a function.
+type WordRef string
+
+func (w WordRef) String() string { return "'" + string(w) }
+
+func (WordRef) kind() string { return "word reference" }
+
+// parseWordRef returns the word referenced by token and true, or false if token
+// is not a word reference.
+func parseWordRef(token string) (WordRef, bool) {
+	if len(token) < 2 || token[0] != '\'' {
+		return "", false
+	}
+	return WordRef(token[1:]), true
+}
+
+// errTooDeep is returned by evalWord when words are nested more than
+// maxEvalDepth deep.
+var errTooDeep = fmt.Errorf("words are nested more than %d deep; does one reference itself?", maxEvalDepth)
+
+// evalWord executes the word or operator w on a sub-stack holding only x and
+// returns the single value it leaves behind.
+func (so *StackOperator) evalWord(w WordRef, x Value) (Value, error) {
+	if so.depth >= maxEvalDepth {
+		return nil, errTooDeep
+	}
+	def, pres := so.Words[string(w)]
+	if !pres {
+		if _, pres := so.Actions.Get(string(w)); !pres {
+			return nil, fmt.Errorf("%s is not a defined word or operator", w)
+		}
+		def = string(w)
+	}
+	tmp := so.subOperator()
+	tmp.depth = so.depth + 1
+	tmp.Stack.Push(x)
+	if err := tmp.ParseInput(def); err != nil {
+		msg := strings.TrimPrefix(strings.TrimSpace(err.Error()), "operation error: ")
+		if msg == errTooDeep.Error() {
+			return nil, errTooDeep
+		}
+		return nil, fmt.Errorf("%s: %s", w, msg)
+	}
+	if n := len(tmp.Stack.Values); n != 1 {
+		return nil, fmt.Errorf("%s must leave exactly 1 value, left %d", w, n)
+	}
+	return tmp.Stack.Values[0], nil
+}
+
+// wordFunc returns w as a real function of one variable.
+func (so *StackOperator) wordFunc(w WordRef) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		v, err := so.evalWord(w, Number(x))
+		if err != nil {
+			return 0, err
+		}
+		y, ok := asFloat(v)
+		if !ok {
+			return 0, fmt.Errorf("%s must leave a number, left %s %v", w, v.kind(), v)
+		}
+		return y, nil
+	}
+}
+
+// popWordRef pops 'a', which must be a word reference. The stack is left
+// untouched if it is not.
+func (so *StackOperator) popWordRef() (WordRef, error) {
+	v := so.Stack.Values[len(so.Stack.Values)-1]
+	w, ok := v.(WordRef)
+	if !ok {
+		return "", fmt.Errorf("expected word reference like 'f, got %s %v", v.kind(), v)
+	}
+	so.Stack.Pop()
+	return w, nil
+}
+
+// SolveError is returned by the numerical Actions when they fail to find an
+// answer. It records how far they got.
+type SolveError struct {
+	// Method is the name of the Action that failed.
+	Method string
+	// Reason describes why it failed.
+	Reason string
+	// Iterations is the number of iterations that were completed.
+	Iterations int
+	// X is the last estimate and Y the value of the function there.
+	X, Y float64
+}
+
+func (e *SolveError) Error() string {
+	after := ""
+	if e.Iterations > 0 {
+		after = fmt.Sprintf(" after %d iterations", e.Iterations)
+	}
+	return fmt.Sprintf("operation error: %s: %s%s (x = %v, f(x) = %v)\n", e.Method, e.Reason, after, e.X, e.Y)
+}
+
+// converged reports whether step is small enough relative to x to stop.
+func (so *StackOperator) converged(step, x float64) bool {
+	return math.Abs(step) <= so.Tolerance*math.Max(1, math.Abs(x))
+}
+
+// newton finds a root of f near x using Newton's method with a numerical
+// derivative.
+func (so *StackOperator) newton(f func(float64) (float64, error), x float64) (float64, error) {
+	var y float64
+	for i := 0; i < so.Iterations; i++ {
+		var err error
+		if y, err = f(x); err != nil {
+			return 0, err
+		}
+		if math.IsNaN(y) || math.IsInf(y, 0) {
+			return 0, &SolveError{"solve", "function is not defined", i, x, y}
+		}
+		if y == 0 {
+			return x, nil
+		}
+		h := 1e-7 * math.Max(1, math.Abs(x))
+		dy, err := derivative(f, x, h)
+		if err != nil {
+			return 0, err
+		}
+		if dy == 0 {
+			return 0, &SolveError{"solve", "derivative is zero", i, x, y}
+		}
+		step := y / dy
+		x -= step
+		if so.converged(step, x) {
+			return x, nil
+		}
+	}
+	y, err := f(x)
+	if err != nil {
+		return 0, err
+	}
+	return 0, &SolveError{"solve", "did not converge", so.Iterations, x, y}
+}
+
+// secant finds a root of f using the secant method, starting from the line
+// through f at x0 and x1.
+func (so *StackOperator) secant(f func(float64) (float64, error), x0, x1 float64) (float64, error) {
+	y0, err := f(x0)
+	if err != nil {
+		return 0, err
+	}
+	y1, err := f(x1)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < so.Iterations; i++ {
+		if math.IsNaN(y1) || math.IsInf(y1, 0) {
+			return 0, &SolveError{"secant", "function is not defined", i, x1, y1}
+		}
+		if y1 == 0 {
+			return x1, nil
+		}
+		if y1 == y0 {
+			return 0, &SolveError{"secant", "secant line is flat", i, x1, y1}
+		}
+		step := y1 * (x1 - x0) / (y1 - y0)
+		x0, y0 = x1, y1
+		x1 -= step
+		if so.converged(step, x1) {
+			return x1, nil
+		}
+		if y1, err = f(x1); err != nil {
+			return 0, err
+		}
+	}
+	return 0, &SolveError{"secant", "did not converge", so.Iterations, x1, y1}
+}
+
+// bisect finds a root of f between lo and hi, where f must change sign.
+func (so *StackOperator) bisect(f func(float64) (float64, error), lo, hi float64) (float64, error) {
+	ylo, err := f(lo)
+	if err != nil {
+		return 0, err
+	}
+	yhi, err := f(hi)
+	if err != nil {
+		return 0, err
+	}
+	if ylo == 0 {
+		return lo, nil
+	}
+	if yhi == 0 {
+		return hi, nil
+	}
+	if math.Signbit(ylo) == math.Signbit(yhi) {
+		return 0, &SolveError{"bisect", "function does not change sign between bounds", 0, lo, ylo}
+	}
+	var mid, y float64
+	for i := 0; i < so.Iterations; i++ {
+		mid = lo + (hi-lo)/2
+		if y, err = f(mid); err != nil {
+			return 0, err
+		}
+		if y == 0 || so.converged(hi-lo, mid) {
+			return mid, nil
+		}
+		if math.Signbit(y) == math.Signbit(ylo) {
+			lo, ylo = mid, y
+		} else {
+			hi = mid
+		}
+	}
+	return 0, &SolveError{"bisect", "did not converge", so.Iterations, mid, y}
+}
+
+// derivative returns the central difference approximation of f'(x) with step h.
+func derivative(f func(float64) (float64, error), x, h float64) (float64, error) {
+	y1, err := f(x + h)
+	if err != nil {
+		return 0, err
+	}
+	y0, err := f(x - h)
+	if err != nil {
+		return 0, err
+	}
+	return (y1 - y0) / (2 * h), nil
+}
+
+// derivative5 returns the five-point stencil approximation of f'(x).
+func derivative5(f func(float64) (float64, error), x float64) (float64, error) {
+	h := 1e-3 * math.Max(1, math.Abs(x))
+	ys := make([]float64, 4)
+	for i, dx := range []float64{2 * h, h, -h, -2 * h} {
+		y, err := f(x + dx)
+		if err != nil {
+			return 0, err
+		}
+		ys[i] = y
+	}
+	return (-ys[0] + 8*ys[1] - 8*ys[2] + ys[3]) / (12 * h), nil
+}
+
+// integrate returns the integral of f from a to b using adaptive Simpson's rule.
+// It gives up if it needs more than evalsPerIteration evaluations of f for each
+// of so.Iterations.
+func (so *StackOperator) integrate(f func(float64) (float64, error), a, b float64) (float64, error) {
+	var err error
+	evals := 0
+	// lastX and lastY are the last point f was evaluated at, for reporting.
+	var lastX, lastY float64
+	eval := func(x float64) float64 {
+		if err != nil {
+			return 0
+		}
+		if evals++; evals > so.Iterations*evalsPerIteration {
+			err = &SolveError{"integrate", "did not converge", so.Iterations, lastX, lastY}
+			return 0
+		}
+		var y float64
+		y, err = f(x)
+		lastX, lastY = x, y
+		if err == nil && (math.IsNaN(y) || math.IsInf(y, 0)) {
+			err = &SolveError{"integrate", "function is not defined", 0, x, y}
+		}
+		return y
+	}
+	simpson := func(a, fa, b, fb float64) (m, fm, whole float64) {
+		m = a + (b-a)/2
+		fm = eval(m)
+		return m, fm, (b - a) / 6 * (fa + 4*fm + fb)
+	}
+	var minEps float64
+	var step func(a, fa, b, fb, m, fm, whole, eps float64, depth int) float64
+	step = func(a, fa, b, fb, m, fm, whole, eps float64, depth int) float64 {
+		lm, flm, left := simpson(a, fa, m, fm)
+		rm, frm, right := simpson(m, fm, b, fb)
+		if err != nil {
+			return 0
+		}
+		delta := left + right - whole
+		if math.Abs(delta) <= 15*eps && depth >= minDepth {
+			return left + right + delta/15
+		}
+		if depth >= maxDepth {
+			err = &SolveError{"integrate", "did not converge", depth, m, fm}
+			return 0
+		}
+		// Halving eps forever would ask for more precision than a float has.
+		eps = math.Max(eps/2, minEps)
+		return step(a, fa, m, fm, lm, flm, left, eps, depth+1) +
+			step(m, fm, b, fb, rm, frm, right, eps, depth+1)
+	}
+	fa, fb := eval(a), eval(b)
+	m, fm, whole := simpson(a, fa, b, fb)
+	eps := so.Tolerance * math.Max(1, math.Abs(whole))
+	minEps = 1e-16 * math.Max(1, math.Abs(whole))
+	total := step(a, fa, b, fb, m, fm, whole, eps, 0)
+	return total, err
+}
+
+// finish pushes result, or returns err with values pushed back onto the stack.
+func (so *StackOperator) finish(result float64, err error, values ...Value) (string, error) {
+	var solveErr *SolveError
+	switch {
+	case errors.As(err, &solveErr):
+		for _, v := range values {
+			so.Stack.Push(v)
+		}
+		return "", err
+	case err != nil:
+		return "", so.Fail(err.Error(), values...)
+	}
+	so.Stack.Push(Number(result))
+	return so.Stack.Display(), nil
+}
+
+// Solve is an Action with the following description: pop 'a', 'b'; push a root
+// of the word 'b' found by Newton's method starting from 'a', or the solution x
+// of the linear system 'b' x = 'a' if 'b' is a matrix and 'a' a vector.
+var Solve = &Action{
+	func(so *StackOperator) (string, error) {
+		l := len(so.Stack.Values)
+		if _, ok := so.Stack.Values[l-1].(Vector); ok {
+			if _, ok := so.Stack.Values[l-2].(Matrix); ok {
+				return so.solveLinear()
+			}
+		}
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		w, err := so.popWordRef()
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(x))
+		}
+		root, err := so.newton(so.wordFunc(w), x)
+		return so.finish(root, err, w, Number(x))
+	}, 2, 1,
+	"Pop 'a', 'b'; push a root of the word 'b' found by Newton's method starting from 'a', or solve the linear system 'b' x = 'a'.",
+}
+
+// Bisect is an Action with the following description: pop 'a', 'b', 'c'; push a
+// root of the word 'c' between 'b' and 'a' found by bisection.
+var Bisect = &Action{
+	func(so *StackOperator) (string, error) {
+		fs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		w, err := so.popWordRef()
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(fs[0]), Number(fs[1]))
+		}
+		root, err := so.bisect(so.wordFunc(w), fs[0], fs[1])
+		return so.finish(root, err, w, Number(fs[0]), Number(fs[1]))
+	}, 3, 1,
+	"Pop 'a', 'b', 'c'; push a root of the word 'c' between 'b' and 'a' found by bisection.",
+}
+
+// Secant is an Action with the following description: pop 'a', 'b', 'c'; push a
+// root of the word 'c' found by the secant method starting from 'b' and 'a'.
+var Secant = &Action{
+	func(so *StackOperator) (string, error) {
+		fs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		w, err := so.popWordRef()
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(fs[0]), Number(fs[1]))
+		}
+		root, err := so.secant(so.wordFunc(w), fs[0], fs[1])
+		return so.finish(root, err, w, Number(fs[0]), Number(fs[1]))
+	}, 3, 1,
+	"Pop 'a', 'b', 'c'; push a root of the word 'c' found by the secant method starting from 'b' and 'a'.",
+}
+
+// Integrate is an Action with the following description: pop 'a', 'b', 'c';
+// push the integral of the word 'c' from 'b' to 'a'.
+var Integrate = &Action{
+	func(so *StackOperator) (string, error) {
+		fs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		w, err := so.popWordRef()
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(fs[0]), Number(fs[1]))
+		}
+		area, err := so.integrate(so.wordFunc(w), fs[0], fs[1])
+		return so.finish(area, err, w, Number(fs[0]), Number(fs[1]))
+	}, 3, 1,
+	"Pop 'a', 'b', 'c'; push the integral of the word 'c' from 'b' to 'a'.",
+}
+
+// Deriv is an Action with the following description: pop 'a', 'b'; push the
+// derivative of the word 'b' at 'a'.
+var Deriv = &Action{
+	func(so *StackOperator) (string, error) {
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		w, err := so.popWordRef()
+		if err != nil {
+			return "", so.Fail(err.Error(), Number(x))
+		}
+		slope, err := derivative5(so.wordFunc(w), x)
+		return so.finish(slope, err, w, Number(x))
+	}, 2, 1,
+	"Pop 'a', 'b'; push the derivative of the word 'b' at 'a'.",
+}
+
+// SetTolerance is an Action with the following description: pop 'a'; set the
+// relative tolerance of the numerical operators to 'a'.
+var SetTolerance = &Action{
+	func(so *StackOperator) (string, error) {
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if x <= 0 {
+			return "", so.Fail("tolerance must be positive", Number(x))
+		}
+		so.Tolerance = x
+		return fmt.Sprintf("tolerance: %v\n", x), nil
+	}, 1, 0,
+	"Pop 'a'; set the relative tolerance of the numerical operators to 'a'.",
+}
+
+// SetIterations is an Action with the following description: pop 'a'; set the
+// maximum number of iterations of the numerical operators to 'a'.
+var SetIterations = &Action{
+	func(so *StackOperator) (string, error) {
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if x < 1 || x != math.Trunc(x) {
+			return "", so.Fail("iteration limit must be a positive integer", Number(x))
+		}
+		so.Iterations = int(x)
+		return fmt.Sprintf("iteration limit: %v\n", x), nil
+	}, 1, 0,
+	"Pop 'a'; set the maximum number of iterations of the numerical operators to 'a'.",
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func newTestOperator() *StackOperator {
+	return NewStackOperator(NewOrderedMap[string, *Action](), -1, false, false, false)
+}
+
+// fn returns f as a function that cannot fail.
+func fn(f func(float64) float64) func(float64) (float64, error) {
+	return func(x float64) (float64, error) { return f(x), nil }
+}
+
+func TestSolvers(t *testing.T) {
+	so := newTestOperator()
+	sq2 := fn(func(x float64) float64 { return x*x - 2 })
+	solvers := map[string]func(f func(float64) (float64, error)) (float64, error){
+		"newton": func(f func(float64) (float64, error)) (float64, error) { return so.newton(f, 1) },
+		"secant": func(f func(float64) (float64, error)) (float64, error) { return so.secant(f, 1, 2) },
+		"bisect": func(f func(float64) (float64, error)) (float64, error) { return so.bisect(f, 0, 2) },
+	}
+	for name, solve := range solvers {
+		root, err := solve(sq2)
+		if err != nil {
+			t.Fatalf("%s : unexpected error %q", name, err)
+		}
+		if math.Abs(root-math.Sqrt2) > 1e-11 {
+			t.Fatalf("%s : expected root %v : got = %v", name, math.Sqrt2, root)
+		}
+	}
+	// Every solver gives up on a function with no root.
+	noRoot := fn(func(x float64) float64 { return x*x + 1 })
+	for name, solve := range solvers {
+		var solveErr *SolveError
+		if _, err := solve(noRoot); !errors.As(err, &solveErr) {
+			t.Fatalf("%s : expected SolveError : got = %v", name, err)
+		}
+	}
+}
+
+func TestSolverLimits(t *testing.T) {
+	so := newTestOperator()
+	so.Iterations = 3
+	var solveErr *SolveError
+	slow := fn(math.Atan)
+	if _, err := so.bisect(slow, -1, 2); !errors.As(err, &solveErr) || solveErr.Iterations != 3 {
+		t.Fatalf("expected bisect to give up after 3 iterations : got = %v", err)
+	}
+	so.Iterations, so.Tolerance = defIterations, 1e-3
+	root, err := so.bisect(slow, -1, 2)
+	if err != nil || math.Abs(root) > 2e-3 {
+		t.Fatalf("expected root within tolerance 1e-3 : got = %v, %v", root, err)
+	}
+	if _, err := so.newton(fn(math.Exp), 1); !errors.As(err, &solveErr) {
+		t.Fatalf("expected newton to fail on exp : got = %v", err)
+	}
+	if _, err := so.secant(fn(func(float64) float64 { return 1 }), 1, 2); !errors.As(err, &solveErr) || solveErr.Reason != "secant line is flat" {
+		t.Fatalf("expected secant to fail on a flat line : got = %v", err)
+	}
+	if _, err := so.newton(fn(math.Log), -1); !errors.As(err, &solveErr) || solveErr.Reason != "function is not defined" {
+		t.Fatalf("expected newton to fail where log is not defined : got = %v", err)
+	}
+}
+
+func TestEvalWordDepth(t *testing.T) {
+	so := newTestOperator()
+	so.Actions.Set("solve", Solve)
+	so.Words["f"] = "'g 1 solve"
+	so.Words["g"] = "f"
+	if _, err := so.evalWord("f", Number(1)); !errors.Is(err, errTooDeep) {
+		t.Fatalf("expected words that reference each other to fail : got = %v", err)
+	}
+	if _, err := so.DefNormWord([]string{"h", "'h", "1", "solve"}); err == nil {
+		t.Fatal("expected word that references itself to not be defined")
+	}
+}
+
+func TestIntegrate(t *testing.T) {
+	so := newTestOperator()
+	tests := []struct {
+		name string
+		f    func(float64) float64
+		a, b float64
+		want float64
+	}{
+		{"sin", math.Sin, 0, math.Pi, 2},
+		{"square", func(x float64) float64 { return x * x }, 0, 3, 9},
+		{"reversed", func(x float64) float64 { return x * x }, 3, 0, -9},
+		{"floor", math.Floor, 0, 10.5, 50},
+		// The spike is too narrow to see from the ends and middle alone.
+		{"spike", func(x float64) float64 { return math.Exp(-1e4 * (x - 0.3) * (x - 0.3)) }, 0, 1, math.Sqrt(math.Pi / 1e4)},
+	}
+	for _, test := range tests {
+		got, err := so.integrate(fn(test.f), test.a, test.b)
+		if err != nil {
+			t.Fatalf("%s : unexpected error %q", test.name, err)
+		}
+		if math.Abs(got-test.want) > 1e-9*math.Max(1, math.Abs(test.want)) {
+			t.Fatalf("%s : expected = %v : got = %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestIntegrateLimits(t *testing.T) {
+	so := newTestOperator()
+	var solveErr *SolveError
+	if _, err := so.integrate(fn(func(x float64) float64 { return 1 / x }), 0, 1); !errors.As(err, &solveErr) || solveErr.Reason != "function is not defined" {
+		t.Fatalf("expected integral of 1/x from 0 to fail : got = %v", err)
+	}
+	so.Iterations = 1
+	wiggly := fn(func(x float64) float64 { return math.Sin(1 / x) })
+	if _, err := so.integrate(wiggly, 0.001, 1); !errors.As(err, &solveErr) || solveErr.Reason != "did not converge" {
+		t.Fatalf("expected integrate to give up after %d evaluations : got = %v", evalsPerIteration, err)
+	}
+	failing := errors.New("failing")
+	if _, err := so.integrate(func(float64) (float64, error) { return 0, failing }, 0, 1); !errors.Is(err, failing) {
+		t.Fatalf("expected error from function to pass through : got = %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+)
+
+// maxFactorial is the largest number whose factorial will be calculated.
+const maxFactorial = 10000
+
+// stepsPerIteration is how many steps of Pollard's rho algorithm factor may
+// take for each iteration that the numerical Actions are allowed.
+const stepsPerIteration = 1000
+
+// Int is an exact integer of any size.
+type Int struct {
+	X *big.Int
+}
+
+func (n Int) String() string { return n.X.String() }
+
+func (Int) kind() string { return "integer" }
+
+func (n Int) float() float64 {
+	f, _ := new(big.Float).SetInt(n.X).Float64()
+	return f
+}
+
+func (n Int) format(prec int) string {
+	return fmt.Sprintf("%.*g", prec, n.float())
+}
+
+func (n Int) arith(op byte, y Value, reversed bool) (Value, error) {
+	m, ok := asInt(y)
+	if !ok {
+		// Anything else gets the integer as a plain number.
+		if reversed {
+			return arith(op, y, Number(n.float()))
+		}
+		return arith(op, Number(n.float()), y)
+	}
+	a, b := n.X, m
+	if reversed {
+		a, b = b, a
+	}
+	z := new(big.Int)
+	switch op {
+	case '+':
+		return Int{z.Add(a, b)}, nil
+	case '-':
+		return Int{z.Sub(a, b)}, nil
+	case '*':
+		return Int{z.Mul(a, b)}, nil
+	case '/', '%':
+		if b.Sign() == 0 {
+			return nil, errors.New("cannot divide by 0")
+		}
+		q, r := z.QuoRem(a, b, new(big.Int))
+		if op == '%' {
+			return Int{r}, nil
+		}
+		if r.Sign() == 0 {
+			return Int{q}, nil
+		}
+	case '^':
+		if b.Sign() >= 0 && b.IsInt64() && int64(a.BitLen())*b.Int64() <= 1<<20 {
+			return Int{z.Exp(a, b, nil)}, nil
+		}
+	}
+	return arith(op, Number(Int{a}.float()), Number(Int{b}.float()))
+}
+
+func (n Int) apply(fn function) (Value, error) {
+	return Number(n.float()).apply(fn)
+}
+
+// parseInt returns the integer written as s if it has too many digits to be
+// represented exactly by a Number.
+func parseInt(s string) (Int, bool) {
+	digits := len(s)
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		digits--
+	}
+	if digits <= 15 {
+		return Int{}, false
+	}
+	x, ok := new(big.Int).SetString(s, 10)
+	return Int{x}, ok
+}
+
+// asInt returns v as a big.Int if v is an Int or a Number with an integer
+// value.
+func asInt(v Value) (*big.Int, bool) {
+	switch v := v.(type) {
+	case Int:
+		return v.X, true
+	case Number:
+		f := float64(v)
+		if math.IsInf(f, 0) || f != math.Trunc(f) {
+			return nil, false
+		}
+		x, _ := big.NewFloat(f).Int(nil)
+		return x, true
+	}
+	return nil, false
+}
+
+// popInts pops n values that must all be integers and returns them in the
+// order they were pushed. The stack is left untouched if any of them is not an
+// integer.
+func (so *StackOperator) popInts(n int) ([]*big.Int, error) {
+	l := len(so.Stack.Values)
+	xs := make([]*big.Int, n)
+	for i, v := range so.Stack.Values[l-n:] {
+		x, ok := asInt(v)
+		if !ok {
+			return nil, fmt.Errorf("expected integer, got %s %v", v.kind(), v)
+		}
+		xs[i] = x
+	}
+	so.Stack.Values = so.Stack.Values[:l-n]
+	return xs, nil
+}
+
+func ints(xs ...*big.Int) []Value {
+	values := make([]Value, len(xs))
+	for i, x := range xs {
+		values[i] = Int{x}
+	}
+	return values
+}
+
+// primeFactors returns the prime factors of n > 1 in ascending order, or an
+// error if finding them takes more than steps steps of Pollard's rho
+// algorithm.
+func primeFactors(n *big.Int, steps int) ([]*big.Int, error) {
+	factors := make([]*big.Int, 0)
+	n = new(big.Int).Set(n)
+	for p := int64(2); p < 1000; p++ {
+		bp := big.NewInt(p)
+		for new(big.Int).Rem(n, bp).Sign() == 0 {
+			factors = append(factors, bp)
+			n.Quo(n, bp)
+		}
+	}
+	var split func(n *big.Int) error
+	split = func(n *big.Int) error {
+		if n.Cmp(big.NewInt(1)) == 0 {
+			return nil
+		}
+		if n.ProbablyPrime(20) {
+			factors = append(factors, n)
+			return nil
+		}
+		d := pollardRho(n, &steps)
+		if d == nil {
+			return fmt.Errorf("could not factor %v in time; raise maxiter to keep trying", n)
+		}
+		if err := split(d); err != nil {
+			return err
+		}
+		return split(new(big.Int).Quo(n, d))
+	}
+	if err := split(n); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(factors, func(a, b *big.Int) int { return a.Cmp(b) })
+	return factors, nil
+}
+
+// pollardRho returns a non-trivial factor of the composite number n, or nil if
+// it takes more than *steps steps to find one. It subtracts the steps it takes
+// from *steps.
+func pollardRho(n *big.Int, steps *int) *big.Int {
+	one := big.NewInt(1)
+	for c := int64(1); *steps > 0; c++ {
+		x, y, d := big.NewInt(2), big.NewInt(2), big.NewInt(1)
+		bc := big.NewInt(c)
+		step := func(x *big.Int) {
+			x.Mul(x, x).Add(x, bc).Mod(x, n)
+		}
+		for d.Cmp(one) == 0 {
+			if *steps--; *steps < 0 {
+				return nil
+			}
+			step(x)
+			step(y)
+			step(y)
+			d.GCD(nil, nil, new(big.Int).Abs(new(big.Int).Sub(x, y)), n)
+		}
+		if d.Cmp(n) != 0 {
+			return d
+		}
+	}
+	return nil
+}
+
+func factorial(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, errors.New("cannot take factorial of negative number")
+	}
+	if n.Cmp(big.NewInt(maxFactorial)) > 0 {
+		return nil, fmt.Errorf("cannot take factorial of number greater than %d", maxFactorial)
+	}
+	return new(big.Int).MulRange(1, n.Int64()), nil
+}
+
+func intAction(pops int, f func(xs []*big.Int) (*big.Int, error)) func(so *StackOperator) (string, error) {
+	return func(so *StackOperator) (string, error) {
+		xs, err := so.popInts(pops)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		z, err := f(xs)
+		if err != nil {
+			return "", so.Fail(err.Error(), ints(xs...)...)
+		}
+		so.Stack.Push(Int{z})
+		return so.Stack.Display(), nil
+	}
+}
+
+// GCD is an Action with the following description: pop 'a', 'b'; push the
+// greatest common divisor of 'a' and 'b'.
+var GCD = &Action{
+	intAction(2, func(xs []*big.Int) (*big.Int, error) {
+		return new(big.Int).GCD(nil, nil, xs[0], xs[1]), nil
+	}), 2, 1,
+	"Pop 'a', 'b'; push the greatest common divisor of 'a' and 'b'.",
+}
+
+// LCM is an Action with the following description: pop 'a', 'b'; push the
+// least common multiple of 'a' and 'b'.
+var LCM = &Action{
+	intAction(2, func(xs []*big.Int) (*big.Int, error) {
+		if xs[0].Sign() == 0 || xs[1].Sign() == 0 {
+			return new(big.Int), nil
+		}
+		z := new(big.Int).Mul(xs[0], xs[1])
+		z.Abs(z)
+		return z.Quo(z, new(big.Int).GCD(nil, nil, xs[0], xs[1])), nil
+	}), 2, 1,
+	"Pop 'a', 'b'; push the least common multiple of 'a' and 'b'.",
+}
+
+// IsPrime is an Action with the following description: pop 'a'; push 1 if 'a'
+// is prime, or 0 if it is not.
+var IsPrime = &Action{
+	intAction(1, func(xs []*big.Int) (*big.Int, error) {
+		if xs[0].Sign() > 0 && xs[0].ProbablyPrime(20) {
+			return big.NewInt(1), nil
+		}
+		return big.NewInt(0), nil
+	}), 1, 1,
+	"Pop 'a'; push 1 if 'a' is prime, or 0 if it is not.",
+}
+
+// NextPrime is an Action with the following description: pop 'a'; push the
+// smallest prime number greater than 'a'.
+var NextPrime = &Action{
+	intAction(1, func(xs []*big.Int) (*big.Int, error) {
+		z := new(big.Int).Set(xs[0])
+		if z.Sign() < 0 {
+			z.SetInt64(0)
+		}
+		for z.Add(z, big.NewInt(1)); !z.ProbablyPrime(20); z.Add(z, big.NewInt(1)) {
+		}
+		return z, nil
+	}), 1, 1,
+	"Pop 'a'; push the smallest prime number greater than 'a'.",
+}
+
+// Factor is an Action with the following description: pop 'a'; push the prime
+// factors of 'a' in ascending order.
+var Factor = &Action{
+	func(so *StackOperator) (string, error) {
+		xs, err := so.popInts(1)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		n := xs[0]
+		if n.Cmp(big.NewInt(2)) < 0 {
+			return "", so.Fail("cannot factor number less than 2", Int{n})
+		}
+		factors, err := primeFactors(n, so.Iterations*stepsPerIteration)
+		if err != nil {
+			return "", so.Fail(err.Error(), Int{n})
+		}
+		if len(so.Stack.Values)+len(factors) > cap(so.Stack.Values) && !so.Stack.Expandable {
+			return "", so.Fail(fmt.Sprintf("%d prime factors would overflow stack", len(factors)), Int{n})
+		}
+		for _, p := range factors {
+			so.Stack.Push(Int{p})
+		}
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the prime factors of 'a' in ascending order.",
+}
+
+// ModPow is an Action with the following description: pop 'a', 'b', 'c'; push
+// 'c' raised to the power 'b' modulo 'a'.
+var ModPow = &Action{
+	intAction(3, func(xs []*big.Int) (*big.Int, error) {
+		if xs[2].Sign() <= 0 {
+			return nil, errors.New("modulus must be positive")
+		}
+		if xs[1].Sign() < 0 {
+			return nil, errors.New("cannot raise to negative power; use modinv")
+		}
+		return new(big.Int).Exp(xs[0], xs[1], xs[2]), nil
+	}), 3, 1,
+	"Pop 'a', 'b', 'c'; push 'c' raised to the power 'b' modulo 'a'.",
+}
+
+// ModInv is an Action with the following description: pop 'a', 'b'; push the
+// inverse of 'b' modulo 'a'.
+var ModInv = &Action{
+	intAction(2, func(xs []*big.Int) (*big.Int, error) {
+		if xs[1].Sign() <= 0 {
+			return nil, errors.New("modulus must be positive")
+		}
+		z := new(big.Int).ModInverse(xs[0], xs[1])
+		if z == nil {
+			return nil, fmt.Errorf("%v has no inverse modulo %v", xs[0], xs[1])
+		}
+		return z, nil
+	}), 2, 1,
+	"Pop 'a', 'b'; push the inverse of 'b' modulo 'a'.",
+}
+
+// Choose is an Action with the following description: pop 'a', 'b'; push the
+// number of ways to choose 'a' items from 'b' items.
+var Choose = &Action{
+	intAction(2, func(xs []*big.Int) (*big.Int, error) {
+		n, k := xs[0], xs[1]
+		if n.Sign() < 0 || k.Sign() < 0 {
+			return nil, errors.New("cannot choose negative number of items")
+		}
+		if k.Cmp(n) > 0 {
+			return new(big.Int), nil
+		}
+		if n.Cmp(big.NewInt(maxFactorial)) > 0 {
+			return nil, fmt.Errorf("cannot choose from more than %d items", maxFactorial)
+		}
+		return new(big.Int).Binomial(n.Int64(), k.Int64()), nil
+	}), 2, 1,
+	"Pop 'a', 'b'; push the number of ways to choose 'a' items from 'b' items.",
+}
+
+// Permute is an Action with the following description: pop 'a', 'b'; push the
+// number of ways to arrange 'a' items from 'b' items.
+var Permute = &Action{
+	intAction(2, func(xs []*big.Int) (*big.Int, error) {
+		n, k := xs[0], xs[1]
+		if n.Sign() < 0 || k.Sign() < 0 {
+			return nil, errors.New("cannot arrange negative number of items")
+		}
+		if k.Cmp(n) > 0 {
+			return new(big.Int), nil
+		}
+		if n.Cmp(big.NewInt(maxFactorial)) > 0 {
+			return nil, fmt.Errorf("cannot arrange more than %d items", maxFactorial)
+		}
+		return new(big.Int).MulRange(n.Int64()-k.Int64()+1, n.Int64()), nil
+	}), 2, 1,
+	"Pop 'a', 'b'; push the number of ways to arrange 'a' items from 'b' items.",
+}
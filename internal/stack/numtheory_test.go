@@ -0,0 +1,45 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestPrimeFactors(t *testing.T) {
+	tests := map[string][]int64{
+		"2":                   {2},
+		"360":                 {2, 2, 2, 3, 3, 5},
+		"1000003":             {1000003},
+		"1000000016000000063": {1000000007, 1000000009},
+	}
+	for s, want := range tests {
+		n, _ := new(big.Int).SetString(s, 10)
+		factors, err := primeFactors(n, defIterations*stepsPerIteration)
+		if err != nil {
+			t.Fatalf("n = %s : unexpected error %q", s, err)
+		}
+		got := make([]int64, len(factors))
+		for i, f := range factors {
+			got[i] = f.Int64()
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("n = %s : expected = %v : got = %v", s, want, got)
+		}
+	}
+}
+
+func TestPrimeFactorsLimit(t *testing.T) {
+	// The product of two 19 digit primes takes far too many steps to factor.
+	n, _ := new(big.Int).SetString("3000000000000000046000000000000000111", 10)
+	if _, err := primeFactors(n, defIterations*stepsPerIteration); err == nil {
+		t.Fatal("expected factoring to give up")
+	}
+	// Any budget at all is enough for a number with only small factors.
+	if factors, err := primeFactors(big.NewInt(2*3*5*7*11), 1); err != nil || len(factors) != 5 {
+		t.Fatalf("expected 5 factors : got = %v, %v", factors, err)
+	}
+}
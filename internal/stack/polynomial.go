@@ -0,0 +1,259 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"slices"
+)
+
+// Polynomials are vectors of coefficients, highest power first, so [1 -3 2] is
+// x^2 - 3x + 2.
+
+// trimPoly returns p without leading zero coefficients.
+func trimPoly(p []float64) Vector {
+	for len(p) > 1 && p[0] == 0 {
+		p = p[1:]
+	}
+	return Vector(p)
+}
+
+// popVectors pops n values that must all be vectors and returns them in the
+// order they were pushed. The stack is left untouched if any of them is not a
+// vector.
+func (so *StackOperator) popVectors(n int) ([]Vector, error) {
+	l := len(so.Stack.Values)
+	vs := make([]Vector, n)
+	for i, v := range so.Stack.Values[l-n:] {
+		vec, ok := v.(Vector)
+		if !ok {
+			return nil, fmt.Errorf("expected vector of coefficients, got %s %v", v.kind(), v)
+		}
+		vs[i] = vec
+	}
+	so.Stack.Values = so.Stack.Values[:l-n]
+	return vs, nil
+}
+
+func polyAction(pops int, f func(ps []Vector) Vector) func(so *StackOperator) (string, error) {
+	return func(so *StackOperator) (string, error) {
+		ps, err := so.popVectors(pops)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		so.Stack.Push(trimPoly(f(ps)))
+		return so.Stack.Display(), nil
+	}
+}
+
+// polyRoots returns the complex roots of p, sorted by real then imaginary part.
+func (so *StackOperator) polyRoots(p Vector) ([]complex128, error) {
+	p = trimPoly(p)
+	roots := make([]complex128, 0, len(p)-1)
+	// Trailing zeros are roots at 0.
+	for len(p) > 1 && p[len(p)-1] == 0 {
+		roots = append(roots, 0)
+		p = p[:len(p)-1]
+	}
+	switch n := len(p) - 1; {
+	case n == 1:
+		roots = append(roots, complex(-p[1]/p[0], 0))
+	case n == 2:
+		a, b, c := p[0], p[1], p[2]
+		sq := cmplx.Sqrt(complex(b*b-4*a*c, 0))
+		// Avoid subtracting nearly equal numbers.
+		if b < 0 {
+			sq = -sq
+		}
+		q := -(complex(b, 0) + sq) / 2
+		roots = append(roots, q/complex(a, 0), complex(c, 0)/q)
+	case n > 2:
+		found, err := so.durandKerner(p)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, found...)
+	}
+	for i, r := range roots {
+		// Adding 0 turns -0 into 0.
+		roots[i] = complex(real(r)+0, imag(r)+0)
+		if math.Abs(imag(r)) <= 1e-12*math.Max(1, cmplx.Abs(r)) {
+			roots[i] = complex(real(r)+0, 0)
+		}
+	}
+	slices.SortFunc(roots, func(a, b complex128) int {
+		return cmp.Or(cmp.Compare(real(a), real(b)), cmp.Compare(imag(a), imag(b)))
+	})
+	return roots, nil
+}
+
+// durandKerner finds every root of p at once with the Durand-Kerner method.
+func (so *StackOperator) durandKerner(p Vector) ([]complex128, error) {
+	n := len(p) - 1
+	eval := func(x complex128) complex128 {
+		var y complex128
+		for _, c := range p {
+			y = y*x + complex(c/p[0], 0)
+		}
+		return y
+	}
+	roots := make([]complex128, n)
+	for i := range roots {
+		roots[i] = cmplx.Pow(0.4+0.9i, complex(float64(i), 0))
+	}
+	for iter := 0; iter < 10*so.Iterations; iter++ {
+		var change float64
+		for i, r := range roots {
+			d := complex(1, 0)
+			for j, s := range roots {
+				if i != j {
+					d *= r - s
+				}
+			}
+			if d == 0 {
+				d = complex(so.Tolerance, 0)
+			}
+			step := eval(r) / d
+			roots[i] = r - step
+			change = math.Max(change, cmplx.Abs(step)/math.Max(1, cmplx.Abs(r)))
+		}
+		if change <= so.Tolerance {
+			// A few Newton steps on each root clean up the last digits.
+			for i, r := range roots {
+				for k := 0; k < 3; k++ {
+					var y, dy complex128
+					for _, c := range p {
+						dy = dy*r + y
+						y = y*r + complex(c, 0)
+					}
+					if dy == 0 {
+						break
+					}
+					r -= y / dy
+				}
+				roots[i] = r
+			}
+			return roots, nil
+		}
+	}
+	return nil, &SolveError{"roots", "did not converge", 10 * so.Iterations, real(roots[0]), cmplx.Abs(eval(roots[0]))}
+}
+
+// PolyVal is an Action with the following description: pop 'a', 'b'; push the
+// value of polynomial 'b' at 'a'.
+var PolyVal = &Action{
+	func(so *StackOperator) (string, error) {
+		y := so.Stack.Pop()
+		x, ok := asComplex(y)
+		if !ok {
+			return "", so.Fail(fmt.Sprintf("cannot evaluate polynomial at %s", y.kind()), y)
+		}
+		ps, err := so.popVectors(1)
+		if err != nil {
+			return "", so.Fail(err.Error(), y)
+		}
+		var sum complex128
+		for _, c := range ps[0] {
+			sum = sum*x + complex(c, 0)
+		}
+		so.Stack.Push(newComplex(sum))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push the value of polynomial 'b' at 'a'.",
+}
+
+// PolyAdd is an Action with the following description: pop 'a', 'b'; push the
+// sum of polynomials 'b' and 'a'.
+var PolyAdd = &Action{
+	polyAction(2, func(ps []Vector) Vector {
+		a, b := ps[0], ps[1]
+		if len(a) < len(b) {
+			a, b = b, a
+		}
+		sum := slices.Clone(a)
+		for i, c := range b {
+			sum[len(a)-len(b)+i] += c
+		}
+		return sum
+	}), 2, 1,
+	"Pop 'a', 'b'; push the sum of polynomials 'b' and 'a'.",
+}
+
+// PolyMul is an Action with the following description: pop 'a', 'b'; push the
+// product of polynomials 'b' and 'a'.
+var PolyMul = &Action{
+	polyAction(2, func(ps []Vector) Vector {
+		a, b := ps[0], ps[1]
+		prod := make(Vector, len(a)+len(b)-1)
+		for i, x := range a {
+			for j, y := range b {
+				prod[i+j] += x * y
+			}
+		}
+		return prod
+	}), 2, 1,
+	"Pop 'a', 'b'; push the product of polynomials 'b' and 'a'.",
+}
+
+// PolyDer is an Action with the following description: pop 'a'; push the
+// derivative of polynomial 'a'.
+var PolyDer = &Action{
+	polyAction(1, func(ps []Vector) Vector {
+		p := ps[0]
+		if len(p) == 1 {
+			return Vector{0}
+		}
+		der := make(Vector, len(p)-1)
+		for i := range der {
+			der[i] = p[i] * float64(len(p)-1-i)
+		}
+		return der
+	}), 1, 1,
+	"Pop 'a'; push the derivative of polynomial 'a'.",
+}
+
+// PolyInt is an Action with the following description: pop 'a'; push the
+// integral of polynomial 'a' with a constant term of 0.
+var PolyInt = &Action{
+	polyAction(1, func(ps []Vector) Vector {
+		p := ps[0]
+		integral := make(Vector, len(p)+1)
+		for i, c := range p {
+			integral[i] = c / float64(len(p)-i)
+		}
+		return integral
+	}), 1, 1,
+	"Pop 'a'; push the integral of polynomial 'a' with a constant term of 0.",
+}
+
+// Roots is an Action with the following description: pop 'a'; push every root
+// of polynomial 'a', which may be complex.
+var Roots = &Action{
+	func(so *StackOperator) (string, error) {
+		ps, err := so.popVectors(1)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		p := ps[0]
+		if len(trimPoly(p)) == 1 {
+			return "", so.Fail("constant polynomial has no roots", p)
+		}
+		roots, err := so.polyRoots(p)
+		if err != nil {
+			so.Stack.Push(p)
+			return "", err
+		}
+		if len(so.Stack.Values)+len(roots) > cap(so.Stack.Values) && !so.Stack.Expandable {
+			return "", so.Fail(fmt.Sprintf("%d roots would overflow stack", len(roots)), p)
+		}
+		for _, r := range roots {
+			so.Stack.Push(newComplex(r))
+		}
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push every root of polynomial 'a', which may be complex.",
+}
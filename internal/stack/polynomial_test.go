@@ -0,0 +1,62 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestPolyRoots(t *testing.T) {
+	so := newTestOperator()
+	tests := []struct {
+		p    Vector
+		want []complex128
+	}{
+		{Vector{2, -4}, []complex128{2}},
+		{Vector{1, -3, 2}, []complex128{1, 2}},
+		{Vector{1, 0, 1}, []complex128{-1i, 1i}},
+		// The small root would lose every digit to cancellation if found
+		// with the textbook formula.
+		{Vector{1, -1e8, 1}, []complex128{1e-8, 1e8}},
+		{Vector{0, 1, -1, 0, 0}, []complex128{0, 0, 1}},
+		{Vector{1, -6, 11, -6}, []complex128{1, 2, 3}},
+		{Vector{1, 0, 0, 0, -1}, []complex128{-1, -1i, 1i, 1}},
+	}
+	for _, test := range tests {
+		got, err := so.polyRoots(test.p)
+		if err != nil {
+			t.Fatalf("%v : unexpected error %q", test.p, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("%v : expected = %v : got = %v", test.p, test.want, got)
+		}
+		for i, r := range test.want {
+			if cmplx.Abs(got[i]-r) > 1e-12*math.Max(1, cmplx.Abs(r)) {
+				t.Fatalf("%v : expected = %v : got = %v", test.p, test.want, got)
+			}
+		}
+	}
+}
+
+func TestPolyRootsLimit(t *testing.T) {
+	so := newTestOperator()
+	so.Iterations = 1
+	var solveErr *SolveError
+	// (x-1)^4 has a repeated root, which converges slowly.
+	if _, err := so.polyRoots(Vector{1, -4, 6, -4, 1}); !errors.As(err, &solveErr) {
+		t.Fatalf("expected roots to give up after %d iterations : got = %v", 10*so.Iterations, err)
+	}
+}
+
+func TestTrimPoly(t *testing.T) {
+	if got := trimPoly([]float64{0, 0, 1, 0}); got.String() != (Vector{1, 0}).String() {
+		t.Fatalf("expected = [1 0] : got = %v", got)
+	}
+	if got := trimPoly([]float64{0}); len(got) != 1 {
+		t.Fatalf("expected = [0] : got = %v", got)
+	}
+}
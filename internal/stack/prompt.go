@@ -0,0 +1,445 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// spec is a prompt format specifier like &8.3t: a verb with an optional width
+// and precision, which are -1 if not given.
+type spec struct {
+	verb        byte
+	width, prec int
+}
+
+// promptFormatter returns the text that the specifier sp stands for.
+type promptFormatter func(so *StackOperator, sp spec) string
+
+// segment is one piece of a parsed prompt format.
+type segment interface {
+	render(so *StackOperator) string
+}
+
+// literal is text in a prompt format that is printed as is.
+type literal string
+
+func (l literal) render(*StackOperator) string { return string(l) }
+
+// specifier is a prompt format specifier and the formatter that fills it in.
+type specifier struct {
+	spec
+	f promptFormatter
+}
+
+func (s specifier) render(so *StackOperator) string {
+	text := s.f(so, s.spec)
+	if n := s.width - utf8.RuneCountInString(text); n > 0 {
+		text = strings.Repeat(" ", n) + text
+	}
+	return text
+}
+
+func renderAll(so *StackOperator, segs []segment) string {
+	sb := new(strings.Builder)
+	for _, s := range segs {
+		sb.WriteString(s.render(so))
+	}
+	return sb.String()
+}
+
+// condition is a conditional prompt segment. It renders then if left is true,
+// meaning that it is not empty or 0, or if there is a right side and left is
+// the same as right. Otherwise it renders els.
+type condition struct {
+	left, right []segment
+	negate      bool
+	then, els   []segment
+}
+
+func (c *condition) render(so *StackOperator) string {
+	left := renderAll(so, c.left)
+	var ok bool
+	if c.right != nil {
+		ok = left == renderAll(so, c.right)
+	} else {
+		ok = left != "" && left != "0"
+	}
+	if ok != c.negate {
+		return renderAll(so, c.then)
+	}
+	return renderAll(so, c.els)
+}
+
+// style is a terminal escape sequence that styles the rest of the prompt. It
+// renders as nothing if StackOperator.Color is false.
+type style string
+
+func (s style) render(so *StackOperator) string {
+	if !so.Color {
+		return ""
+	}
+	return string(s)
+}
+
+// styleCodes are the SGR parameters of the named styles and colors.
+var styleCodes = map[string]int{
+	"reset":     0,
+	"bold":      1,
+	"dim":       2,
+	"italic":    3,
+	"underline": 4,
+	"blink":     5,
+	"reverse":   7,
+	"black":     30,
+	"red":       31,
+	"green":     32,
+	"yellow":    33,
+	"blue":      34,
+	"magenta":   35,
+	"cyan":      36,
+	"white":     37,
+}
+
+// parseStyle returns the escape sequence for name, which is one of the names
+// in styleCodes, a color from 0 to 255, or a hex color like #ff8800. Colors can
+// be prefixed with fg: or bg: to set the foreground or background, and named
+// colors with bright- for the bright version.
+func parseStyle(name string) (style, error) {
+	base := 30
+	color := name
+	if c, found := strings.CutPrefix(name, "bg:"); found {
+		base, color = 40, c
+	} else {
+		color = strings.TrimPrefix(name, "fg:")
+	}
+	bright := strings.HasPrefix(color, "bright-")
+	code, pres := styleCodes[strings.TrimPrefix(color, "bright-")]
+	switch {
+	case pres && code >= 30:
+		code += base - 30
+		if bright {
+			code += 60
+		}
+		return style(fmt.Sprintf("\x1b[%dm", code)), nil
+	case pres && !bright && color == name:
+		return style(fmt.Sprintf("\x1b[%dm", code)), nil
+	case strings.HasPrefix(color, "#") && len(color) == 7:
+		rgb, err := strconv.ParseUint(color[1:], 16, 24)
+		if err != nil {
+			break
+		}
+		return style(fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", base+8, rgb>>16, rgb>>8&0xff, rgb&0xff)), nil
+	default:
+		if n, err := strconv.ParseUint(color, 10, 8); err == nil {
+			return style(fmt.Sprintf("\x1b[%d;5;%dm", base+8, n)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown style '%s'", name)
+}
+
+// PromptError is returned when a prompt format cannot be parsed. Column is the
+// position of the offending character, counting from 1.
+type PromptError struct {
+	Format string
+	Column int
+	Reason string
+}
+
+func (e *PromptError) Error() string {
+	return fmt.Sprintf("could not parse prompt : %s at column %d\n%s\n%*s\n",
+		e.Reason, e.Column, e.Format, e.Column, "^")
+}
+
+// promptParser turns a prompt format into segments.
+type promptParser struct {
+	format  string
+	fmtChar byte
+	pos     int
+	so      *StackOperator
+}
+
+func (p *promptParser) fail(pos int, reason string, a ...any) error {
+	return &PromptError{p.format, utf8.RuneCountInString(p.format[:pos]) + 1, fmt.Sprintf(reason, a...)}
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// number reads the digits at the current position and returns them as an int,
+// or -1 if there are none.
+func (p *promptParser) number() int {
+	if p.pos >= len(p.format) || !isDigit(p.format[p.pos]) {
+		return -1
+	}
+	n := 0
+	for ; p.pos < len(p.format) && isDigit(p.format[p.pos]); p.pos++ {
+		n = min(n*10+int(p.format[p.pos]-'0'), 1<<16)
+	}
+	return n
+}
+
+// parse returns the segments of the format up to the end or the first
+// character in stop that is not part of a specifier or escaped.
+func (p *promptParser) parse(stop string) ([]segment, error) {
+	segs := make([]segment, 0)
+	text := new(strings.Builder)
+	flush := func() {
+		if text.Len() > 0 {
+			segs = append(segs, literal(text.String()))
+			text.Reset()
+		}
+	}
+	for p.pos < len(p.format) && strings.IndexByte(stop, p.format[p.pos]) < 0 {
+		c := p.format[p.pos]
+		switch {
+		case c == '\\':
+			s, err := p.escape()
+			if err != nil {
+				return nil, err
+			}
+			text.WriteString(s)
+		case c == p.fmtChar && p.startsSpec():
+			s, err := p.specifier()
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			segs = append(segs, s)
+		default:
+			text.WriteByte(c)
+			p.pos++
+		}
+	}
+	flush()
+	return segs, nil
+}
+
+// startsSpec reports whether the format character at the current position
+// starts a specifier. A format character followed by anything else, like a
+// space or the end of the format, is printed as is.
+func (p *promptParser) startsSpec() bool {
+	if p.pos+1 >= len(p.format) {
+		return false
+	}
+	c := p.format[p.pos+1]
+	return isDigit(c) || isLetter(c) || c == '.' || c == '{' || c == '?'
+}
+
+// escape reads a backslash escape: \\, \n, or a backslash before the format
+// character or a brace.
+func (p *promptParser) escape() (string, error) {
+	start := p.pos
+	p.pos += 2
+	if start+1 >= len(p.format) {
+		return "", p.fail(start, "unfinished escape")
+	}
+	switch c := p.format[start+1]; c {
+	case '\\', p.fmtChar, '{', '}':
+		return string(c), nil
+	case 'n':
+		return "\n", nil
+	}
+	return "", p.fail(start, "unknown escape \\%c", p.format[start+1])
+}
+
+// specifier reads a specifier starting with the format character.
+func (p *promptParser) specifier() (segment, error) {
+	start := p.pos
+	p.pos++
+	if p.format[p.pos] == '?' {
+		return p.conditional()
+	}
+	sp := spec{width: p.number(), prec: -1}
+	if p.pos < len(p.format) && p.format[p.pos] == '.' {
+		p.pos++
+		if sp.prec = p.number(); sp.prec < 0 {
+			return nil, p.fail(p.pos, "missing precision after '.'")
+		}
+	}
+	if p.pos < len(p.format) && p.format[p.pos] == '{' {
+		return p.braced()
+	}
+	if p.pos >= len(p.format) || !isLetter(p.format[p.pos]) {
+		return nil, p.fail(p.pos, "missing specifier after %s", p.format[start:p.pos])
+	}
+	sp.verb = p.format[p.pos]
+	if sp.verb == 'v' {
+		p.pos++
+		return p.valueWord(sp)
+	}
+	f, pres := p.so.formatters[sp.verb]
+	if !pres {
+		return nil, p.fail(p.pos, "unknown specifier '%c'", sp.verb)
+	}
+	p.pos++
+	return specifier{sp, f}, nil
+}
+
+// name reads a name in braces and returns it.
+func (p *promptParser) name() (string, error) {
+	start := p.pos
+	if start >= len(p.format) || p.format[start] != '{' {
+		return "", p.fail(start, "missing {")
+	}
+	end := strings.IndexByte(p.format[start:], '}')
+	if end < 0 {
+		return "", p.fail(start, "missing } after %s", p.format[start:])
+	}
+	p.pos = start + end + 1
+	if end == 1 {
+		return "", p.fail(start+1, "missing name")
+	}
+	return p.format[start+1 : start+end], nil
+}
+
+// valueWord reads the name of a value word or constant in braces after &v, like
+// &v{pi}. Value words can be defined after the prompt is made, so a name that
+// is not defined shows nothing.
+func (p *promptParser) valueWord(sp spec) (segment, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	return specifier{sp, valueFormatter(func(so *StackOperator) Value {
+		if v, pres := so.ValWords[name]; pres {
+			return v
+		}
+		v, _ := lookupConstant(name)
+		return v
+	})}, nil
+}
+
+// braced reads a style in braces, like &{red}.
+func (p *promptParser) braced() (segment, error) {
+	start := p.pos
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseStyle(name)
+	if err != nil {
+		return nil, p.fail(start+1, "%v", err)
+	}
+	return s, nil
+}
+
+// block reads a format in braces. If sep is not 0, the format can be split in
+// two by sep, and the second part is returned as well.
+func (p *promptParser) block(sep byte) (first, second []segment, err error) {
+	if p.pos >= len(p.format) || p.format[p.pos] != '{' {
+		return nil, nil, p.fail(p.pos, "missing {")
+	}
+	p.pos++
+	stop := "}"
+	if sep != 0 {
+		stop += string(sep)
+	}
+	if first, err = p.parse(stop); err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.format) && p.format[p.pos] == sep {
+		p.pos++
+		if second, err = p.parse("}"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.pos >= len(p.format) {
+		return nil, nil, p.fail(p.pos, "missing }")
+	}
+	p.pos++
+	return first, second, nil
+}
+
+// conditional reads a conditional segment like &?{cond}{then}{else}, with the
+// else part being optional. A ! before the condition negates it.
+func (p *promptParser) conditional() (segment, error) {
+	p.pos++
+	c := new(condition)
+	if p.pos < len(p.format) && p.format[p.pos] == '!' {
+		c.negate = true
+		p.pos++
+	}
+	var err error
+	if c.left, c.right, err = p.block('='); err != nil {
+		return nil, err
+	}
+	if c.then, _, err = p.block(0); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.format) && p.format[p.pos] == '{' {
+		if c.els, _, err = p.block(0); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// compilePrompt returns a function that builds the prompt described by format
+// every time it is called. Specifiers in format start with fmtChar.
+func (so *StackOperator) compilePrompt(format string, fmtChar byte) (func() string, error) {
+	p := &promptParser{format: format, fmtChar: fmtChar, so: so}
+	segs, err := p.parse("")
+	if err != nil {
+		return nil, err
+	}
+	return func() string { return renderAll(so, segs) }, nil
+}
+
+// MakePromptFunc sets StackOperator.Prompt to a function that builds the prompt
+// described by format every time it is called. Specifiers in format start with
+// fmtChar. It returns a *PromptError if format cannot be parsed.
+func (so *StackOperator) MakePromptFunc(format string, fmtChar byte) (err error) {
+	so.Prompt, err = so.compilePrompt(format, fmtChar)
+	return err
+}
+
+// MakeRightPromptFunc is like MakePromptFunc, but sets
+// StackOperator.RightPrompt, which is shown at the right edge of the line that
+// input is typed on.
+func (so *StackOperator) MakeRightPromptFunc(format string, fmtChar byte) (err error) {
+	so.RightPrompt, err = so.compilePrompt(format, fmtChar)
+	return err
+}
+
+// topValues is the formatter for &Nt, the top N values in the stack, with N
+// being the stack limit if not given.
+func topValues(so *StackOperator, sp spec) string {
+	n, prec := sp.width, sp.prec
+	if n < 0 {
+		n = cap(so.Stack.Values)
+	}
+	if prec < 0 {
+		prec = 6
+	}
+	last := make([]string, n)
+	l := len(so.Stack.Values)
+	for i := 0; i < n; i++ {
+		p := n - i - 1
+		if i > l-1 {
+			last[p] = "N"
+		} else {
+			last[p] = formatValue(so.Stack.Values[l-i-1], prec)
+		}
+	}
+	return strings.Join(last, " ")
+}
+
+// valueFormatter returns a formatter for the value returned by v, printed in
+// full unless a precision is given, or nothing if v returns nil.
+func valueFormatter(v func(so *StackOperator) Value) promptFormatter {
+	return func(so *StackOperator, sp spec) string {
+		val := v(so)
+		switch {
+		case val == nil:
+			return ""
+		case sp.prec < 0:
+			return val.String()
+		}
+		return formatValue(val, sp.prec)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// newRand returns a random number generator seeded from the current time.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// poisson returns a random number from a Poisson distribution with mean lambda.
+func poisson(r *rand.Rand, lambda float64) float64 {
+	if lambda < 30 {
+		// Multiply uniform numbers until the product drops below e^-lambda.
+		limit := math.Exp(-lambda)
+		k, p := 0., r.Float64()
+		for p > limit {
+			k++
+			p *= r.Float64()
+		}
+		return k
+	}
+	// Knuth's method is too slow for large means, so use Hörmann's transformed
+	// rejection with squeeze.
+	slam, loglam := math.Sqrt(lambda), math.Log(lambda)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invAlpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := r.Float64() - 0.5
+		v := r.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return k
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invAlpha)-math.Log(a/(us*us)+b) <= -lambda+k*loglam-lg {
+			return k
+		}
+	}
+}
+
+// Seed is an Action with the following description: pop 'a'; seed the random
+// number generator with 'a'.
+var Seed = &Action{
+	func(so *StackOperator) (string, error) {
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if x != math.Trunc(x) || math.Abs(x) >= 1<<63 {
+			return "", so.Fail("seed must be an integer", Number(x))
+		}
+		so.Rand.Seed(int64(x))
+		return fmt.Sprintf("random seed: %v\n", x), nil
+	}, 1, 0,
+	"Pop 'a'; seed the random number generator with 'a'.",
+}
+
+// RandInt is an Action with the following description: pop 'a', 'b'; push a
+// random integer from 'b' to 'a'.
+var RandInt = &Action{
+	func(so *StackOperator) (string, error) {
+		xs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		lo, hi := xs[0], xs[1]
+		if lo != math.Trunc(lo) || hi != math.Trunc(hi) {
+			return "", so.Fail("bounds must be integers", Number(lo), Number(hi))
+		}
+		if lo > hi {
+			return "", so.Fail("lower bound is greater than upper bound", Number(lo), Number(hi))
+		}
+		if hi-lo >= 1<<62 {
+			return "", so.Fail("bounds are too far apart", Number(lo), Number(hi))
+		}
+		so.Stack.Push(Number(lo + float64(so.Rand.Int63n(int64(hi-lo)+1))))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push a random integer from 'b' to 'a'.",
+}
+
+// Normal is an Action with the following description: pop 'a', 'b'; push a
+// random number from a normal distribution with mean 'b' and standard
+// deviation 'a'.
+var Normal = &Action{
+	func(so *StackOperator) (string, error) {
+		xs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		mean, sd := xs[0], xs[1]
+		if sd < 0 {
+			return "", so.Fail("standard deviation cannot be negative", Number(mean), Number(sd))
+		}
+		so.Stack.Push(Number(mean + sd*so.Rand.NormFloat64()))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push a random number from a normal distribution with mean 'b' and standard deviation 'a'.",
+}
+
+// Exponential is an Action with the following description: pop 'a'; push a
+// random number from an exponential distribution with mean 'a'.
+var Exponential = &Action{
+	func(so *StackOperator) (string, error) {
+		mean, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if mean <= 0 {
+			return "", so.Fail("mean must be positive", Number(mean))
+		}
+		so.Stack.Push(Number(mean * so.Rand.ExpFloat64()))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push a random number from an exponential distribution with mean 'a'.",
+}
+
+// Poisson is an Action with the following description: pop 'a'; push a random
+// integer from a Poisson distribution with mean 'a'.
+var Poisson = &Action{
+	func(so *StackOperator) (string, error) {
+		mean, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if mean < 0 || math.IsInf(mean, 0) || math.IsNaN(mean) {
+			return "", so.Fail("mean must be a non-negative number", Number(mean))
+		}
+		so.Stack.Push(Number(poisson(so.Rand, mean)))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push a random integer from a Poisson distribution with mean 'a'.",
+}
+
+// Choice is an Action with the following description: pop all values in the
+// stack; push one of them chosen at random.
+var Choice = &Action{
+	func(so *StackOperator) (string, error) {
+		v := so.Stack.Values[so.Rand.Intn(len(so.Stack.Values))]
+		so.Stack.Values = so.Stack.Values[:0]
+		so.Stack.Push(v)
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop all values in the stack; push one of them chosen at random.",
+}
+
+// Shuffle is an Action with the following description: shuffle the values in
+// the stack into a random order.
+var Shuffle = &Action{
+	func(so *StackOperator) (string, error) {
+		vs := so.Stack.Values
+		so.Rand.Shuffle(len(vs), func(i, j int) { vs[i], vs[j] = vs[j], vs[i] })
+		return so.Stack.Display(), nil
+	}, 0, 0,
+	"Shuffle the values in the stack into a random order.",
+}
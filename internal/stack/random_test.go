@@ -0,0 +1,61 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPoisson(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	if k := poisson(r, 0); k != 0 {
+		t.Fatalf("mean 0 : expected = 0 : got = %v", k)
+	}
+	const n = 20000
+	// The small means use Knuth's method and the large ones rejection.
+	for _, lambda := range []float64{0.5, 4, 29, 30, 100, 1e6} {
+		var sum, sumSq float64
+		for i := 0; i < n; i++ {
+			k := poisson(r, lambda)
+			if k < 0 || k != math.Trunc(k) {
+				t.Fatalf("mean %v : got non-integer %v", lambda, k)
+			}
+			sum += k
+			sumSq += k * k
+		}
+		mean := sum / n
+		variance := sumSq/n - mean*mean
+		// Both should be lambda, to within several standard errors.
+		if se := math.Sqrt(lambda / n); math.Abs(mean-lambda) > 5*se {
+			t.Fatalf("mean %v : got sample mean %v", lambda, mean)
+		}
+		if math.Abs(variance-lambda) > 0.1*lambda {
+			t.Fatalf("mean %v : got sample variance %v", lambda, variance)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	draw := func(seed float64) [3]float64 {
+		so := newTestOperator()
+		so.Stack.Push(Number(seed))
+		if _, err := Seed.action(so); err != nil {
+			t.Fatalf("seed %v : unexpected error %q", seed, err)
+		}
+		return [3]float64{so.Rand.Float64(), poisson(so.Rand, 50), so.Rand.NormFloat64()}
+	}
+	if a, b := draw(42), draw(42); a != b {
+		t.Fatalf("same seed gave different numbers : %v and %v", a, b)
+	}
+	if a, b := draw(42), draw(43); a == b {
+		t.Fatalf("different seeds gave the same numbers : %v", a)
+	}
+	so := newTestOperator()
+	so.Stack.Push(Number(1.5))
+	if _, err := Seed.action(so); err == nil {
+		t.Fatal("expected error seeding with 1.5")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"math"
+)
+
+// Curve fit models supported by regression.
+const (
+	linearModel byte = 'l'
+	expModel    byte = 'e'
+	logModel    byte = 'g'
+	powerModel  byte = 'p'
+)
+
+// regression holds the coefficients of the last curve fit. Every model is
+// fitted as a straight line through transformed data, so a is always the
+// intercept-like coefficient and b the slope-like coefficient.
+type regression struct {
+	model byte
+	a, b  float64
+}
+
+// fitCurve fits model to the x, y pairs in values, which must alternate x and
+// y. It returns the fitted regression and the correlation coefficient of the
+// transformed data, which is 0 if y does not vary: a flat line fits exactly,
+// but y then does not depend on x at all.
+func fitCurve(model byte, values []float64) (fit *regression, r float64, err error) {
+	xs := make([]float64, 0, len(values)/2)
+	ys := make([]float64, 0, len(values)/2)
+	var sx, sy float64
+	for i := 0; i+1 < len(values); i += 2 {
+		x, y := values[i], values[i+1]
+		if model == logModel || model == powerModel {
+			if x <= 0 {
+				return nil, 0, errors.New("cannot fit logarithmic or power curve to non-positive x")
+			}
+			x = math.Log(x)
+		}
+		if model == expModel || model == powerModel {
+			if y <= 0 {
+				return nil, 0, errors.New("cannot fit exponential or power curve to non-positive y")
+			}
+			y = math.Log(y)
+		}
+		xs, ys = append(xs, x), append(ys, y)
+		sx += x
+		sy += y
+	}
+	// Sum the squares of the differences from the means in a second pass,
+	// which loses much less precision than subtracting sums of squares.
+	n := float64(len(xs))
+	mx, my := sx/n, sy/n
+	var sxx, syy, dxx, dyy, dxy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		dxx += dx * dx
+		dyy += dy * dy
+		dxy += dx * dy
+	}
+	if flat(dxx, sxx) {
+		return nil, 0, errors.New("cannot fit curve to data with no variation in x")
+	}
+	b := dxy / dxx
+	if flat(dyy, syy) {
+		b = 0
+	}
+	a := my - b*mx
+	if model == expModel || model == powerModel {
+		a = math.Exp(a)
+	}
+	if b == 0 {
+		return &regression{model, a, 0}, 0, nil
+	}
+	return &regression{model, a, b}, dxy / math.Sqrt(dxx*dyy), nil
+}
+
+// flat reports whether d, the sum of the squared differences of some numbers
+// from their mean, is only rounding error compared to s, the sum of their
+// squares.
+func flat(d, s float64) bool {
+	const eps = 0x1p-52
+	return d <= 64*eps*eps*s
+}
+
+// y returns the value of the fitted curve at x.
+func (fit *regression) y(x float64) float64 {
+	switch fit.model {
+	case expModel:
+		return fit.a * math.Exp(fit.b*x)
+	case logModel:
+		return fit.a + fit.b*math.Log(x)
+	case powerModel:
+		return fit.a * math.Pow(x, fit.b)
+	}
+	return fit.a + fit.b*x
+}
+
+// x returns the value at which the fitted curve equals y.
+func (fit *regression) x(y float64) float64 {
+	switch fit.model {
+	case expModel:
+		return math.Log(y/fit.a) / fit.b
+	case logModel:
+		return math.Exp((y - fit.a) / fit.b)
+	case powerModel:
+		return math.Pow(y/fit.a, 1/fit.b)
+	}
+	return (y - fit.a) / fit.b
+}
+
+func fitAction(model byte) func(so *StackOperator) (string, error) {
+	return func(so *StackOperator) (string, error) {
+		if len(so.Stack.Values)%2 != 0 {
+			return "", so.Fail("curve fit needs x, y pairs; stack has an odd number of values")
+		}
+		values, err := floats(so.Stack.Values)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		fit, r, err := fitCurve(model, values)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		so.Stack.Values = so.Stack.Values[:0]
+		so.fit = fit
+		so.Stack.Push(Number(fit.b))
+		so.Stack.Push(Number(fit.a))
+		so.Stack.Push(Number(r))
+		return so.Stack.Display(), nil
+	}
+}
+
+// LinReg is an Action with the following description: pop all values as x, y
+// pairs; fit y = a + b*x; push 'b', 'a', and the correlation coefficient.
+var LinReg = &Action{
+	fitAction(linearModel), 4, 3,
+	"Pop all values as x, y pairs; fit y = a + b*x; push slope 'b', intercept 'a', and correlation 'r'.",
+}
+
+// ExpReg is an Action with the following description: pop all values as x, y
+// pairs; fit y = a*e^(b*x); push 'b', 'a', and the correlation coefficient.
+var ExpReg = &Action{
+	fitAction(expModel), 4, 3,
+	"Pop all values as x, y pairs; fit y = a*e^(b*x); push 'b', 'a', and correlation 'r'.",
+}
+
+// LogReg is an Action with the following description: pop all values as x, y
+// pairs; fit y = a + b*ln(x); push 'b', 'a', and the correlation coefficient.
+var LogReg = &Action{
+	fitAction(logModel), 4, 3,
+	"Pop all values as x, y pairs; fit y = a + b*ln(x); push 'b', 'a', and correlation 'r'.",
+}
+
+// PowReg is an Action with the following description: pop all values as x, y
+// pairs; fit y = a*x^b; push 'b', 'a', and the correlation coefficient.
+var PowReg = &Action{
+	fitAction(powerModel), 4, 3,
+	"Pop all values as x, y pairs; fit y = a*x^b; push 'b', 'a', and correlation 'r'.",
+}
+
+// YHat is an Action with the following description: pop 'a'; push the value
+// of the last fitted curve at x = 'a'.
+var YHat = &Action{
+	func(so *StackOperator) (string, error) {
+		x, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if so.fit == nil {
+			return "", so.Fail("no curve fitted yet", Number(x))
+		}
+		so.Stack.Push(Number(so.fit.y(x)))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the y value of the last fitted curve at x = 'a'.",
+}
+
+// XHat is an Action with the following description: pop 'a'; push the x value
+// at which the last fitted curve equals 'a'.
+var XHat = &Action{
+	func(so *StackOperator) (string, error) {
+		y, err := so.popFloat()
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		if so.fit == nil {
+			return "", so.Fail("no curve fitted yet", Number(y))
+		}
+		if so.fit.b == 0 {
+			return "", so.Fail("cannot invert curve with zero slope", Number(y))
+		}
+		so.Stack.Push(Number(so.fit.x(y)))
+		return so.Stack.Display(), nil
+	}, 1, 1,
+	"Pop 'a'; push the x value at which the last fitted curve equals 'a'.",
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitCurve(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    byte
+		values   []float64
+		a, b, r  float64
+		wantFail bool
+	}{
+		{"line", linearModel, []float64{1, 2, 2, 4, 3, 6}, 0, 2, 1, false},
+		{"falling line", linearModel, []float64{1, 3, 2, 2, 3, 1}, 4, -1, -1, false},
+		{"flat", linearModel, []float64{1, 2, 2, 2, 3, 2}, 2, 0, 0, false},
+		{"inexact flat", linearModel, []float64{1, 0.1, 2, 0.1, 3, 0.1}, 0.1, 0, 0, false},
+		{"same x", linearModel, []float64{1, 1, 1, 2, 1, 3}, 0, 0, 0, true},
+		{"inexact same x", linearModel, []float64{0.1, 1, 0.1, 2, 0.1, 3}, 0, 0, 0, true},
+		{"exponential", expModel, []float64{0, 1, 1, 2, 2, 4}, 1, math.Ln2, 1, false},
+		{"power", powerModel, []float64{1, 2, 2, 4, 4, 8}, 2, 1, 1, false},
+		{"logarithmic", logModel, []float64{1, 1, math.E, 2, math.E * math.E, 3}, 1, 1, 1, false},
+		{"power of zero", powerModel, []float64{0, 1, 1, 2}, 0, 0, 0, true},
+		{"exponential of zero", expModel, []float64{0, 0, 1, 2}, 0, 0, 0, true},
+	}
+	for _, test := range tests {
+		fit, r, err := fitCurve(test.model, test.values)
+		if test.wantFail {
+			if err == nil {
+				t.Fatalf("%s : wanted error, got a = %v, b = %v, r = %v", test.name, fit.a, fit.b, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s : unexpected error %q", test.name, err)
+		}
+		if math.Abs(fit.a-test.a) > 1e-12 || math.Abs(fit.b-test.b) > 1e-12 || math.Abs(r-test.r) > 1e-12 {
+			t.Fatalf("%s : expected a = %v, b = %v, r = %v : got = %v, %v, %v",
+				test.name, test.a, test.b, test.r, fit.a, fit.b, r)
+		}
+	}
+}
+
+func TestFitInverse(t *testing.T) {
+	fits := []*regression{
+		{linearModel, 1, 2}, {expModel, 2, 0.5}, {logModel, 1, 3}, {powerModel, 2, 1.5},
+	}
+	for _, fit := range fits {
+		if x := fit.x(fit.y(3)); math.Abs(x-3) > 1e-12 {
+			t.Fatalf("model = %c : expected x(y(3)) = 3 : got = %v", fit.model, x)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SessionVersion is the version of the session file format. It goes up every
+// time the format changes in a way that older versions cannot read.
+const SessionVersion = 1
+
+// ErrNoSession is returned when loading a session that was never saved.
+var ErrNoSession = errors.New("no session")
+
+// Session is everything about a StackOperator that is kept when it is saved
+// as a session. Values are stored as the literals that push them.
+type Session struct {
+	Version    int               `json:"version"`
+	Saved      time.Time         `json:"saved"`
+	Stack      []string          `json:"stack"`
+	Stash      string            `json:"stash"`
+	Limit      int               `json:"limit"`
+	Angle      string            `json:"angle"`
+	Display    bool              `json:"display"`
+	Strict     bool              `json:"strict"`
+	Tolerance  float64           `json:"tolerance"`
+	Iterations int               `json:"iterations"`
+	TVM        TVM               `json:"tvm"`
+	Units      map[string]string `json:"units"`
+	Words      map[string]string `json:"words"`
+	ValWords   map[string]string `json:"values"`
+	History    []string          `json:"history"`
+}
+
+// literals returns the literal of every value in m.
+func literals(m map[string]Value) map[string]string {
+	lits := make(map[string]string, len(m))
+	for k, v := range m {
+		lits[k] = Literal(v)
+	}
+	return lits
+}
+
+// Snapshot returns the state of so as a Session.
+func (so *StackOperator) Snapshot() *Session {
+	s := &Session{
+		Version:    SessionVersion,
+		Saved:      time.Now(),
+		Stack:      make([]string, len(so.Stack.Values)),
+		Stash:      Literal(so.Stack.Stash),
+		Limit:      cap(so.Stack.Values),
+		Angle:      so.Angle.String(),
+		Display:    so.Stack.displayFmt != "",
+		Strict:     so.strict,
+		Tolerance:  so.Tolerance,
+		Iterations: so.Iterations,
+		TVM:        *so.TVM,
+		Units:      literals(so.Units),
+		Words:      so.Words,
+		ValWords:   literals(so.ValWords),
+		History:    so.History,
+	}
+	if so.Stack.Expandable {
+		s.Limit = -1
+	}
+	for i, v := range so.Stack.Values {
+		s.Stack[i] = Literal(v)
+	}
+	return s
+}
+
+// parseValue returns the value that lit, a literal returned by Literal, pushes.
+func (so *StackOperator) parseValue(lit string) (Value, error) {
+	tmp := so.subOperator()
+	if _, err := tmp.parseToken(lit); err != nil {
+		return nil, errors.New(strings.TrimSpace(err.Error()))
+	}
+	if len(tmp.Stack.Values) != 1 {
+		return nil, fmt.Errorf("%s is not a value", lit)
+	}
+	return tmp.Stack.Values[0], nil
+}
+
+// parseValues returns the value of every literal in lits.
+func (so *StackOperator) parseValues(lits map[string]string) (map[string]Value, error) {
+	m := make(map[string]Value, len(lits))
+	for k, lit := range lits {
+		v, err := so.parseValue(lit)
+		if err != nil {
+			return nil, fmt.Errorf("could not restore %s : %v", k, err)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// Restore sets the state of so to s. It leaves so as it was if s could not be
+// restored.
+func (so *StackOperator) Restore(s *Session) error {
+	if s.Version == 0 {
+		return errors.New("not a goclacker session")
+	}
+	if s.Version > SessionVersion {
+		return fmt.Errorf("session has version %d, but this goclacker only reads up to version %d", s.Version, SessionVersion)
+	}
+	angles := map[string]AngleMode{"RAD": RadianMode, "DEG": DegreeMode, "GRAD": GradianMode}
+	angle, found := angles[s.Angle]
+	if !found {
+		return fmt.Errorf("unknown angle mode %s", s.Angle)
+	}
+	if s.Limit >= 0 && len(s.Stack) > s.Limit {
+		return fmt.Errorf("session has %d values, more than its limit of %d", len(s.Stack), s.Limit)
+	}
+	// Values may have units that the session defines, so parse them with a
+	// StackOperator that has those units.
+	tmp := so.subOperator()
+	units, err := tmp.parseValues(s.Units)
+	if err != nil {
+		return err
+	}
+	tmp.Units = units
+	valWords, err := tmp.parseValues(s.ValWords)
+	if err != nil {
+		return err
+	}
+	stackCap, expandable := s.Limit, s.Limit < 0
+	if expandable {
+		stackCap = max(8, len(s.Stack))
+	}
+	values := make([]Value, len(s.Stack), stackCap)
+	for i, lit := range s.Stack {
+		if values[i], err = tmp.parseValue(lit); err != nil {
+			return fmt.Errorf("could not restore stack : %v", err)
+		}
+	}
+	stash, err := tmp.parseValue(s.Stash)
+	if err != nil {
+		return fmt.Errorf("could not restore stash : %v", err)
+	}
+	so.Stack.Values, so.Stack.Expandable, so.Stack.Stash = values, expandable, stash
+	so.Stack.displayFmt = displayFormat(so.Interactive, s.Display)
+	so.strict = s.Strict
+	so.Angle, so.Tolerance, so.Iterations = angle, s.Tolerance, s.Iterations
+	tvm := s.TVM
+	so.TVM = &tvm
+	so.Units, so.ValWords = units, valWords
+	so.Words = s.Words
+	if so.Words == nil {
+		so.Words = make(map[string]string)
+	}
+	so.History = slices.Clone(s.History[max(0, len(s.History)-maxHistory):])
+	return nil
+}
+
+// sessionPath returns the path of the session file called name.
+func (so *StackOperator) sessionPath(name string) (string, error) {
+	if so.SessionDir == "" {
+		return "", errors.New("no session directory")
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid session name %q", name)
+	}
+	return filepath.Join(so.SessionDir, name+".json"), nil
+}
+
+// SaveSession saves the state of so as the session called name, replacing the
+// session that was there.
+func (so *StackOperator) SaveSession(name string) error {
+	path, err := so.sessionPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(so.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(so.SessionDir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash while saving does not
+	// destroy the last save.
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// LoadSession restores the session called name.
+func (so *StackOperator) LoadSession(name string) error {
+	path, err := so.sessionPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w called %s", ErrNoSession, name)
+	}
+	if err != nil {
+		return err
+	}
+	s := new(Session)
+	if err = json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("could not read session %s : %v", name, err)
+	}
+	return so.Restore(s)
+}
+
+// sessions returns the names of every saved session.
+func (so *StackOperator) sessions() ([]string, error) {
+	entries, err := os.ReadDir(so.SessionDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if name, found := strings.CutSuffix(e.Name(), ".json"); found && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ParseSession runs a session command, which is "session" followed by "save",
+// "load", or "list" and the name of a session. A session that is saved or
+// loaded becomes the current session, which is saved again after every line
+// entered in interactive mode.
+func (so *StackOperator) ParseSession(def []string) (message string, err error) {
+	usage := errors.New("save session: session save name; load session: session load name; list sessions: session list\n")
+	if len(def) < 2 {
+		return "", usage
+	}
+	switch {
+	case def[1] == "list" && len(def) == 2:
+		names, err := so.sessions()
+		if err != nil {
+			return "", fmt.Errorf("could not list sessions : %v\n", err)
+		}
+		if len(names) == 0 {
+			return "no saved sessions\n", nil
+		}
+		return strings.Join(names, "\n") + "\n", nil
+	case def[1] == "save" && len(def) == 3:
+		if err := so.SaveSession(def[2]); err != nil {
+			return "", fmt.Errorf("could not save session %s : %v\n", def[2], err)
+		}
+		so.Session = def[2]
+		return fmt.Sprintf("saved session %s\n", def[2]), nil
+	case def[1] == "load" && len(def) == 3:
+		if err := so.LoadSession(def[2]); err != nil {
+			return "", fmt.Errorf("could not load session %s : %v\n", def[2], err)
+		}
+		so.Session = def[2]
+		return fmt.Sprintf("loaded session %s\n", def[2]) + so.Stack.Display(), nil
+	}
+	return "", usage
+}
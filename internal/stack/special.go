@@ -0,0 +1,260 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"math"
+)
+
+// isPole reports whether x is a non-positive integer, where the gamma function
+// is undefined.
+func isPole(x float64) bool {
+	return x <= 0 && x == math.Trunc(x)
+}
+
+func gammaPole(name string) func(float64) string {
+	return func(x float64) string {
+		if isPole(x) {
+			return fmt.Sprintf("cannot take %s of non-positive integer", name)
+		}
+		return ""
+	}
+}
+
+func nonPositiveBessel(x float64) string {
+	if x <= 0 {
+		return "cannot take Bessel function of the second kind of non-positive number"
+	}
+	return ""
+}
+
+// Special functions applied by Actions to the values in the stack.
+var (
+	gammaFn     = function{"gamma", math.Gamma, gammaPole("gamma"), false, nil, false}
+	logGamma    = function{"log gamma", func(x float64) float64 { lg, _ := math.Lgamma(x); return lg }, gammaPole("log gamma"), false, nil, false}
+	factorialFn = function{"factorial", func(x float64) float64 { return math.Gamma(x + 1) }, func(x float64) string {
+		if isPole(x + 1) {
+			return "cannot take factorial of negative integer"
+		}
+		return ""
+	}, false, nil, false}
+
+	erf = function{"error function", math.Erf, nil, false, func(x float64) float64 { return 2 / math.SqrtPi * math.Exp(-x*x) }, false}
+
+	erfc = function{"complementary error function", math.Erfc, nil, false, func(x float64) float64 { return -2 / math.SqrtPi * math.Exp(-x*x) }, false}
+
+	j0 = function{"Bessel function", math.J0, nil, false, func(x float64) float64 { return -math.J1(x) }, false}
+	j1 = function{"Bessel function", math.J1, nil, false, func(x float64) float64 {
+		return (math.J0(x) - math.Jn(2, x)) / 2
+	}, false}
+
+	y0 = function{"Bessel function", math.Y0, nonPositiveBessel, false, func(x float64) float64 { return -math.Y1(x) }, false}
+	y1 = function{"Bessel function", math.Y1, nonPositiveBessel, false, func(x float64) float64 {
+		return (math.Y0(x) - math.Yn(2, x)) / 2
+	}, false}
+
+	sinh  = function{"hyperbolic sine", math.Sinh, nil, false, math.Cosh, false}
+	cosh  = function{"hyperbolic cosine", math.Cosh, nil, false, math.Sinh, false}
+	tanh  = function{"hyperbolic tangent", math.Tanh, nil, false, func(x float64) float64 { return 1 - math.Tanh(x)*math.Tanh(x) }, false}
+	asinh = function{"hyperbolic arcsine", math.Asinh, nil, false, func(x float64) float64 { return 1 / math.Sqrt(x*x+1) }, false}
+	acosh = function{"hyperbolic arccosine", math.Acosh, func(x float64) string {
+		if x < 1 {
+			return "cannot take hyperbolic arccosine of number less than 1"
+		}
+		return ""
+	}, false, func(x float64) float64 { return 1 / math.Sqrt(x*x-1) }, false}
+
+	atanh = function{"hyperbolic arctangent", math.Atanh, func(x float64) string {
+		if x <= -1 || x >= 1 {
+			return "cannot take hyperbolic arctangent of number not between -1 and 1"
+		}
+		return ""
+	}, false, func(x float64) float64 { return 1 / (1 - x*x) }, false}
+)
+
+// besselN returns an Action function that pops 'a', 'b' and pushes the Bessel
+// function f of order 'a' at 'b'.
+func besselN(f func(n int, x float64) float64, secondKind bool) func(so *StackOperator) (string, error) {
+	return func(so *StackOperator) (string, error) {
+		fs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		x, n := fs[0], fs[1]
+		if n != math.Trunc(n) {
+			return "", so.Fail("Bessel function order must be an integer", Number(x), Number(n))
+		}
+		if secondKind && x <= 0 {
+			return "", so.Fail(nonPositiveBessel(x), Number(x), Number(n))
+		}
+		so.Stack.Push(Number(f(int(n), x)))
+		return so.Stack.Display(), nil
+	}
+}
+
+// Gamma is an Action with the following description: pop 'a'; push the gamma
+// function of 'a'.
+var Gamma = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(gammaFn)
+	}, 1, 1,
+	"Pop 'a'; push the gamma function of 'a'.",
+}
+
+// LogGamma is an Action with the following description: pop 'a'; push the
+// natural logarithm of the absolute value of the gamma function of 'a'.
+var LogGamma = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(logGamma)
+	}, 1, 1,
+	"Pop 'a'; push the natural logarithm of the absolute value of the gamma function of 'a'.",
+}
+
+// Beta is an Action with the following description: pop 'a', 'b'; push the beta
+// function of 'b' and 'a'.
+var Beta = &Action{
+	func(so *StackOperator) (string, error) {
+		fs, err := so.popFloats(2)
+		if err != nil {
+			return "", so.Fail(err.Error())
+		}
+		x, y := fs[0], fs[1]
+		if isPole(x) || isPole(y) {
+			return "", so.Fail("cannot take beta function of non-positive integer", Number(x), Number(y))
+		}
+		if isPole(x + y) {
+			so.Stack.Push(Number(0))
+			return so.Stack.Display(), nil
+		}
+		lx, sx := math.Lgamma(x)
+		ly, sy := math.Lgamma(y)
+		lxy, sxy := math.Lgamma(x + y)
+		so.Stack.Push(Number(float64(sx*sy*sxy) * math.Exp(lx+ly-lxy)))
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push the beta function of 'b' and 'a'.",
+}
+
+// Erf is an Action with the following description: pop 'a'; push the error
+// function of 'a'.
+var Erf = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(erf)
+	}, 1, 1,
+	"Pop 'a'; push the error function of 'a'.",
+}
+
+// Erfc is an Action with the following description: pop 'a'; push the
+// complementary error function of 'a'.
+var Erfc = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(erfc)
+	}, 1, 1,
+	"Pop 'a'; push the complementary error function of 'a'.",
+}
+
+// BesselJ0 is an Action with the following description: pop 'a'; push the
+// Bessel function of the first kind of order 0 at 'a'.
+var BesselJ0 = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(j0)
+	}, 1, 1,
+	"Pop 'a'; push the Bessel function of the first kind of order 0 at 'a'.",
+}
+
+// BesselJ1 is an Action with the following description: pop 'a'; push the
+// Bessel function of the first kind of order 1 at 'a'.
+var BesselJ1 = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(j1)
+	}, 1, 1,
+	"Pop 'a'; push the Bessel function of the first kind of order 1 at 'a'.",
+}
+
+// BesselJn is an Action with the following description: pop 'a', 'b'; push the
+// Bessel function of the first kind of order 'a' at 'b'.
+var BesselJn = &Action{
+	besselN(math.Jn, false), 2, 1,
+	"Pop 'a', 'b'; push the Bessel function of the first kind of order 'a' at 'b'.",
+}
+
+// BesselY0 is an Action with the following description: pop 'a'; push the
+// Bessel function of the second kind of order 0 at 'a'.
+var BesselY0 = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(y0)
+	}, 1, 1,
+	"Pop 'a'; push the Bessel function of the second kind of order 0 at 'a'.",
+}
+
+// BesselY1 is an Action with the following description: pop 'a'; push the
+// Bessel function of the second kind of order 1 at 'a'.
+var BesselY1 = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(y1)
+	}, 1, 1,
+	"Pop 'a'; push the Bessel function of the second kind of order 1 at 'a'.",
+}
+
+// BesselYn is an Action with the following description: pop 'a', 'b'; push the
+// Bessel function of the second kind of order 'a' at 'b'.
+var BesselYn = &Action{
+	besselN(math.Yn, true), 2, 1,
+	"Pop 'a', 'b'; push the Bessel function of the second kind of order 'a' at 'b'.",
+}
+
+// Sinh is an Action with the following description: pop 'a'; push the
+// hyperbolic sine of 'a'.
+var Sinh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(sinh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic sine of 'a'.",
+}
+
+// Cosh is an Action with the following description: pop 'a'; push the
+// hyperbolic cosine of 'a'.
+var Cosh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(cosh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic cosine of 'a'.",
+}
+
+// Tanh is an Action with the following description: pop 'a'; push the
+// hyperbolic tangent of 'a'.
+var Tanh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(tanh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic tangent of 'a'.",
+}
+
+// Asinh is an Action with the following description: pop 'a'; push the
+// hyperbolic arcsine of 'a'.
+var Asinh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(asinh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic arcsine of 'a'.",
+}
+
+// Acosh is an Action with the following description: pop 'a'; push the
+// hyperbolic arccosine of 'a'.
+var Acosh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(acosh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic arccosine of 'a'.",
+}
+
+// Atanh is an Action with the following description: pop 'a'; push the
+// hyperbolic arctangent of 'a'.
+var Atanh = &Action{
+	func(so *StackOperator) (string, error) {
+		return so.unary(atanh)
+	}, 1, 1,
+	"Pop 'a'; push the hyperbolic arctangent of 'a'.",
+}
@@ -6,34 +6,37 @@ package stack
 import (
 	"errors"
 	"fmt"
+	"math/rand"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// Stack contains a slice of float64 and methods to operate on that slice.
+// Stack contains a slice of values and methods to operate on that slice.
 type Stack struct {
-	Values     []float64
-	Stash      float64
+	Values     []Value
+	Stash      Value
 	displayFmt string
 	// Expandable signifies whether stack capacity can be increased or not.
 	Expandable bool
 }
 
 // Pop removes the last value in Stack.Values and returns the value removed.
-func (stk *Stack) Pop() float64 {
+func (stk *Stack) Pop() Value {
 	n := len(stk.Values) - 1
 	f := stk.Values[n]
 	stk.Values = stk.Values[:n]
 	return f
 }
 
-// Push attempts to append f to Stack.Values and returns an error if the stack
+// Push attempts to append v to Stack.Values and returns an error if the stack
 // is at capacity.
-func (stk *Stack) Push(f float64) error {
+func (stk *Stack) Push(v Value) error {
 	if len(stk.Values)+1 > cap(stk.Values) && !stk.Expandable {
-		return errors.New(fmt.Sprintf("cannot push %v, stack at capacity (%d)\n", f, cap(stk.Values)))
+		return errors.New(fmt.Sprintf("cannot push %v, stack at capacity (%d)\n", v, cap(stk.Values)))
 	}
-	stk.Values = append(stk.Values, f)
+	stk.Values = append(stk.Values, v)
 	return nil
 }
 
@@ -44,31 +47,57 @@ func (stk *Stack) Display() string {
 		return stk.displayFmt
 	}
 	sNums := make([]string, len(stk.Values))
-	for i, f := range stk.Values {
-		sNums[i] = fmt.Sprint(f)
+	for i, v := range stk.Values {
+		sNums[i] = v.String()
 	}
 	s := strings.Join(sNums, " ")
 	return fmt.Sprintf(stk.displayFmt, s)
 }
 
-func newStack(values []float64, displayFmt string, expandable bool) *Stack {
-	return &Stack{Values: values, displayFmt: displayFmt, Expandable: expandable}
+func newStack(values []Value, displayFmt string, expandable bool) *Stack {
+	return &Stack{Values: values, Stash: Number(0), displayFmt: displayFmt, Expandable: expandable}
 }
 
 // StackOperator contains a map for converting string tokens into operations
 // that can be called to operate on the stack.
 type StackOperator struct {
-	Actions     *OrderedMap[string, *Action]
-	Words       map[string]string
-	ValWords    map[string]float64
-	Stack       *Stack
+	Actions  *OrderedMap[string, *Action]
+	Words    map[string]string
+	ValWords map[string]Value
+	Units    map[string]Value
+	Stack    *Stack
+	TVM      *TVM
+	Angle    AngleMode
+	// Tolerance and Iterations control when the numerical Actions stop.
+	Tolerance  float64
+	Iterations int
+	// Rand is the source of the random Actions.
+	Rand        *rand.Rand
 	Interactive bool
-	Prompt      func() (prompt string)
+	// Color is whether styles in the prompt are printed.
+	Color bool
+	// LastError and LastResult are the error from and the top value after the
+	// last line entered in interactive mode, for the prompt to show.
+	LastError  error
+	LastResult Value
+	Prompt     func() (prompt string)
+	// RightPrompt is nil if there is no right prompt.
+	RightPrompt func() (prompt string)
 	ToPrint     []byte
-	formatters  map[byte]func(*StackOperator) string
-	// notFound should return nil if the StackOperator does not care about
-	// entering missing input, or an error if it does.
-	notFound func(string) error
+	formatters  map[byte]promptFormatter
+	// started is when the StackOperator was made.
+	started time.Time
+	// fit is the last curve fitted by a regression Action.
+	fit *regression
+	// strict is whether entering something that is not defined is an error.
+	strict bool
+	// depth is how many word evaluations so is nested in.
+	depth int
+	// History is the last maxHistory lines entered in interactive mode.
+	History []string
+	// SessionDir is the directory that sessions are saved in, and Session is
+	// the name of the current session, if there is one.
+	SessionDir, Session string
 }
 
 // ParseInput splits an input string into words and interprets each word as a
@@ -77,13 +106,22 @@ type StackOperator struct {
 // with the message returned by the execution of the last token.
 func (so *StackOperator) ParseInput(input string) (err error) {
 	input = strings.TrimSpace(input)
-	split := strings.Split(input, " ")
+	split, err := splitInput(input)
+	if err != nil {
+		so.ToPrint = []byte(so.Stack.Display())
+		return err
+	}
 	for i, token := range split {
-		if token == "=" || token == "==" {
+		if token == "=" || token == "==" || token == "=u" {
 			s, err := so.ParseWordDef(split[i:])
 			so.ToPrint = []byte(s)
 			return err
 		}
+		if token == "session" {
+			s, err := so.ParseSession(split[i:])
+			so.ToPrint = []byte(s)
+			return err
+		}
 		s, err := so.parseToken(token)
 		if err != nil {
 			so.ToPrint = []byte(so.Stack.Display())
@@ -104,9 +142,13 @@ func (so *StackOperator) ParseWordDef(def []string) (message string, err error)
 	}
 	wordType := "word"
 	var extra byte
-	if def[0] == "==" {
+	switch def[0] {
+	case "==":
 		wordType = "value word"
 		extra = '='
+	case "=u":
+		wordType = "unit"
+		extra = 'u'
 	}
 	if len(def) == 1 {
 		return "", errors.New(fmt.Sprintf("define %s: =%c example 2 2 +; remove %s word: =%c example\n", wordType, extra, wordType, extra))
@@ -124,7 +166,7 @@ func (so *StackOperator) ParseWordDef(def []string) (message string, err error)
 	if _, err := strconv.ParseFloat(word, 64); err == nil {
 		return "", errors.New(fmt.Sprintf("could not define %s : cannot redifine number\n", word))
 	}
-	forbidden := []string{"=", "==", "quit"}
+	forbidden := []string{"=", "==", "quit", "session"}
 	for _, s := range forbidden {
 		if word == s {
 			return "", errors.New(fmt.Sprintf("could not define %s : word cannot be any of: %s\n", word, strings.Join(forbidden, " ")))
@@ -133,8 +175,11 @@ func (so *StackOperator) ParseWordDef(def []string) (message string, err error)
 	if _, present := so.Actions.Get(word); present {
 		return "", errors.New(fmt.Sprintf("could not define %s : cannot redifine operator\n", word))
 	}
-	if wordType == "word" {
+	switch wordType {
+	case "word":
 		return so.DefNormWord(noEmpty)
+	case "unit":
+		return so.DefUnit(noEmpty)
 	}
 	return so.DefValWord(noEmpty)
 }
@@ -152,7 +197,7 @@ func (so *StackOperator) DefNormWord(def []string) (msg string, err error) {
 		return fmt.Sprintf("deleted word: %s\n", word), nil
 	}
 	for _, s := range def[1:] {
-		if word == s {
+		if word == s || "'"+word == s {
 			return "", errors.New(fmt.Sprintf("could not define %s : cannot define recursive word\n", word))
 		}
 	}
@@ -173,11 +218,8 @@ func (so *StackOperator) DefValWord(def []string) (msg string, err error) {
 		delete(so.ValWords, word)
 		return fmt.Sprintf("deleted value word: %s\n", word), nil
 	}
-	// TODO: Make so methods return calculated value so don't need temporary
-	// StackOperator
-	tmp := NewStackOperator(so.Actions, -1, false, false, false)
-	tmp.Words = so.Words
-	tmp.Stack.Values = so.Stack.Values
+	tmp := so.subOperator()
+	tmp.Stack.Values = slices.Clone(so.Stack.Values)
 	err = tmp.ParseInput(strings.Join(def[1:], " "))
 	if err != nil {
 		return "", err
@@ -185,9 +227,25 @@ func (so *StackOperator) DefValWord(def []string) (msg string, err error) {
 	if len(tmp.Stack.Values) == 0 {
 		return "", nil
 	}
-	f := tmp.Stack.Values[len(tmp.Stack.Values)-1]
-	so.ValWords[def[0]] = f
-	return fmt.Sprintf("defined value word %s = %g\n", def[0], f), nil
+	v := tmp.Stack.Values[len(tmp.Stack.Values)-1]
+	so.ValWords[def[0]] = v
+	return fmt.Sprintf("defined value word %s = %v\n", def[0], v), nil
+}
+
+// subOperator returns a temporary StackOperator with no stack limit that shares
+// the words, units, and random number generator of so, for evaluating programs
+// on a sub-stack.
+// TODO: Make so methods return calculated value so don't need temporary
+// StackOperator
+func (so *StackOperator) subOperator() *StackOperator {
+	tmp := newStackOperator(so.Actions, -1, false, false, false, so.Rand)
+	tmp.Words = so.Words
+	tmp.ValWords = so.ValWords
+	tmp.Units = so.Units
+	tmp.Angle = so.Angle
+	tmp.Tolerance = so.Tolerance
+	tmp.Iterations = so.Iterations
+	return tmp
 }
 
 // parseToken parses token that should be one word and either pushes it to the
@@ -203,14 +261,68 @@ func (so *StackOperator) parseToken(token string) (toPrint string, err error) {
 		err = so.Stack.Push(val)
 		return so.Stack.Display(), err
 	}
-	f, err := strconv.ParseFloat(token, 64)
-	if err != nil {
+	if val, pres := lookupConstant(token); pres {
+		err = so.Stack.Push(val)
+		return so.Stack.Display(), err
+	}
+	if strings.HasPrefix(token, "[") {
+		v, err := parseArray(token)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("could not parse %s : %v\n", token, err))
+		}
+		err = so.Stack.Push(v)
+		return so.Stack.Display(), err
+	}
+	v, ok := so.parseLiteral(token)
+	if !ok {
 		return so.ExecuteToken(token)
 	}
-	err = so.Stack.Push(f)
+	err = so.Stack.Push(v)
 	return so.Stack.Display(), err
 }
 
+// parseLiteral returns the value written as token and true, or false if token
+// is not a literal.
+func (so *StackOperator) parseLiteral(token string) (Value, bool) {
+	if n, ok := parseInt(token); ok {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return Number(f), true
+	}
+	if num, unit, found := strings.Cut(token, "_"); found {
+		// _unit on its own is 1 of unit.
+		f, err := 1., error(nil)
+		if num != "" {
+			f, err = strconv.ParseFloat(num, 64)
+		}
+		if err != nil {
+			return nil, false
+		}
+		u, err := so.parseUnit(unit)
+		if err != nil {
+			return nil, false
+		}
+		return newQuantity(f, u), true
+	}
+	if u, ok := parseUncertain(token); ok {
+		return u, true
+	}
+	if c, ok := parseComplex(token); ok {
+		return c, true
+	}
+	if t, ok := parseTime(token); ok {
+		return t, true
+	}
+	if d, ok := parseDuration(token); ok {
+		return d, true
+	}
+	if w, ok := parseWordRef(token); ok {
+		return w, true
+	}
+	return nil, false
+}
+
 // ExecuteToken determines if `token` is an Action token or defined word and
 // executes it accordingly. Returns the string and error from doing what it
 // needs to do.
@@ -219,7 +331,14 @@ func (so *StackOperator) ExecuteToken(token string) (toPrint string, err error)
 	if !pres {
 		def, pres := so.Words[token]
 		if !pres {
-			return "", so.notFound(token)
+			if z, ok := parseZone(token); ok {
+				err := so.Stack.Push(z)
+				return so.Stack.Display(), err
+			}
+			if so.strict {
+				return "", errors.New(fmt.Sprintf("command not found: %s\n", token))
+			}
+			return "", nil
 		}
 		err := so.ParseInput(def)
 		return string(so.ToPrint), err
@@ -240,116 +359,102 @@ func (so *StackOperator) ExecuteToken(token string) (toPrint string, err error)
 
 // Fail pushes all values to the stack and returns an error containing
 // `message`. It also prints Stack.Display if the StackOperator is interactive
-func (so *StackOperator) Fail(message string, values ...float64) error {
-	for _, f := range values {
-		so.Stack.Push(f)
+func (so *StackOperator) Fail(message string, values ...Value) error {
+	for _, v := range values {
+		so.Stack.Push(v)
 	}
 	return errors.New(fmt.Sprintf("operation error: %s\n", message))
 }
 
-// MakePromptFunc sets the StackOperator.prompt value that will execute any
-// functions needed to build the prompt and will return a new string every time
-// it is called. This function returns an error if it could not make the prompt
-// function (should always return nil).
-func (so *StackOperator) MakePromptFunc(format string, fmtChar byte) error {
-	getTopSetup := func(n int) func(*StackOperator) string {
-		return func(so *StackOperator) string {
-			last := make([]string, n)
-			for i := 0; i < n; i++ {
-				p := n - i - 1
-				l := len(so.Stack.Values)
-				if i > l-1 {
-					last[p] = "N"
-				} else {
-					last[p] = fmt.Sprintf("%.6g", so.Stack.Values[l-i-1])
-				}
-			}
-			return strings.Join(last, " ")
-		}
-	}
-	promptFuncs := make([]func(*StackOperator) string, 0, strings.Count(format, string(fmtChar)))
-	promptFmt := []byte(format)
-	for i := 0; i < len(format)-1; i++ {
-		if format[i] == fmtChar {
-			next := format[i+1]
-			sb := new(strings.Builder)
-			var extra int
-			for j := i + 1; next >= '0' && next <= '9' && j < len(format)-1; j++ {
-				sb.WriteByte(next)
-				next = format[j+1]
-				extra++
-			}
-			conv, err := strconv.Atoi(sb.String())
-			if err != nil {
-				conv = cap(so.Stack.Values)
-			}
-			so.formatters['t'] = getTopSetup(conv)
-			f := so.formatters[next]
-			if f != nil {
-				promptFuncs = append(promptFuncs, f)
-				promptFmt[i] = '%'
-				promptFmt[i+1] = 's'
-				for j := 0; j < extra; j++ {
-					promptFmt[i+j+2] = 0
-				}
-			}
-			if next != fmtChar {
-				i++
-			}
-		}
+// maxHistory is the number of lines kept in the history, so that sessions,
+// which are saved after every line, do not keep growing.
+const maxHistory = 1000
+
+// Record notes the outcome of line, entered in interactive mode, for the
+// prompt to show and in the history. It saves the current session if there is
+// one, and returns the error from saving it.
+func (so *StackOperator) Record(line string, err error) error {
+	if len(so.History) >= maxHistory {
+		n := copy(so.History, so.History[len(so.History)-maxHistory+1:])
+		so.History = so.History[:n]
 	}
-	buf := []byte{}
-	for _, b := range promptFmt {
-		if b != 0 {
-			buf = append(buf, b)
-		}
+	so.History = append(so.History, line)
+	so.LastError = err
+	if err == nil && len(so.Stack.Values) > 0 {
+		so.LastResult = so.Stack.Values[len(so.Stack.Values)-1]
 	}
-	noNull := string(buf)
-	if n := strings.Count(noNull, "%s"); n != len(promptFuncs) {
-		return errors.New(fmt.Sprintf("Prompt screwed up...\nformat num = %d : func num = %d", n, len(promptFuncs)))
+	if so.Session == "" {
+		return nil
 	}
-	vals := make([]any, len(promptFuncs))
-
-	so.Prompt = func() string {
-		for i, f := range promptFuncs {
-			vals[i] = f(so)
-		}
-		return fmt.Sprintf(noNull, vals...)
+	if err := so.SaveSession(so.Session); err != nil {
+		return fmt.Errorf("could not save session %s : %v\n", so.Session, err)
 	}
 	return nil
 }
 
+// displayFormat returns the format that a Stack displays its values with.
+func displayFormat(interactive bool, display bool) string {
+	switch {
+	case !display:
+		return ""
+	case interactive:
+		return "[ %s ]\n"
+	}
+	return "%s\n"
+}
+
 // NewStackOperator returns a pointer to a new StackOperator, initialized to
 // given arguments and a default set of defined words and formatters.
 func NewStackOperator(actions *OrderedMap[string, *Action], maxStack int, interactive bool, Display bool, strict bool) *StackOperator {
-	displayFmt := "%s\n"
-	if interactive {
-		displayFmt = "[ %s ]\n"
-	}
-	if !Display {
-		displayFmt = ""
-	}
-	notFound := func(string) error { return nil }
-	if strict {
-		notFound = func(s string) error { return errors.New(fmt.Sprintf("command not found: %s\n", s)) }
-	}
+	return newStackOperator(actions, maxStack, interactive, Display, strict, newRand())
+}
+
+// newStackOperator is NewStackOperator, but it uses r for the random Actions
+// instead of seeding a new generator.
+func newStackOperator(actions *OrderedMap[string, *Action], maxStack int, interactive bool, Display bool, strict bool, r *rand.Rand) *StackOperator {
+	displayFmt := displayFormat(interactive, Display)
 	stackCap := maxStack
 	expandable := maxStack < 0
 	if expandable {
 		stackCap = 8
 	}
 	return &StackOperator{
-		Stack:       &Stack{make([]float64, 0, stackCap), 0, displayFmt, expandable},
+		Stack:       newStack(make([]Value, 0, stackCap), displayFmt, expandable),
 		Actions:     actions,
-		notFound:    notFound,
+		TVM:         newTVM(),
+		Tolerance:   defTolerance,
+		Iterations:  defIterations,
+		Rand:        r,
+		started:     time.Now(),
+		strict:      strict,
 		Interactive: interactive,
 		Words:       make(map[string]string),
-		ValWords:    make(map[string]float64),
-		formatters: map[byte]func(*StackOperator) string{
-			'l': func(so *StackOperator) string { return fmt.Sprint(cap(so.Stack.Values)) },
-			'c': func(so *StackOperator) string { return fmt.Sprint(len(so.Stack.Values)) },
-			's': func(so *StackOperator) string { return fmt.Sprint(so.Stack.Stash) },
-			't': func(*StackOperator) string { return "" },
+		ValWords:    make(map[string]Value),
+		Units:       make(map[string]Value),
+		formatters: map[byte]promptFormatter{
+			'l': func(so *StackOperator, _ spec) string { return fmt.Sprint(cap(so.Stack.Values)) },
+			'c': func(so *StackOperator, _ spec) string { return fmt.Sprint(len(so.Stack.Values)) },
+			's': valueFormatter(func(so *StackOperator) Value { return so.Stack.Stash }),
+			'a': func(so *StackOperator, _ spec) string { return so.Angle.String() },
+			't': topValues,
+			'e': func(so *StackOperator, _ spec) string {
+				if so.LastError == nil {
+					return ""
+				}
+				return strings.TrimSpace(so.LastError.Error())
+			},
+			'r': valueFormatter(func(so *StackOperator) Value { return so.LastResult }),
+			'd': func(so *StackOperator, _ spec) string {
+				if so.Stack.displayFmt == "" {
+					return "off"
+				}
+				return "on"
+			},
+			'w': func(so *StackOperator, _ spec) string { return fmt.Sprint(len(so.Words)) },
+			'n': func(so *StackOperator, _ spec) string { return so.Session },
+			'u': func(so *StackOperator, _ spec) string {
+				return time.Since(so.started).Round(time.Second).String()
+			},
 		},
 	}
 }
@@ -3,7 +3,10 @@
 
 package stack
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func getMaps() (*OrderedMap[string, int], map[string]int) {
 	om := NewOrderedMap[string, int]()
@@ -39,3 +42,22 @@ func TestOrderedMapGet(t *testing.T) {
 		}
 	}
 }
+
+func TestHistoryLimit(t *testing.T) {
+	so := NewStackOperator(NewOrderedMap[string, *Action](), -1, false, false, false)
+	for i := range maxHistory + 5 {
+		so.Record(fmt.Sprint(i), nil)
+	}
+	if len(so.History) != maxHistory || so.History[0] != "5" || so.History[maxHistory-1] != fmt.Sprint(maxHistory+4) {
+		t.Fatalf("expected the last %d lines in history : got %d lines from %q to %q",
+			maxHistory, len(so.History), so.History[0], so.History[len(so.History)-1])
+	}
+	s := so.Snapshot()
+	s.History = append(s.History, "more")
+	if err := so.Restore(s); err != nil {
+		t.Fatalf("unexpected error restoring : %q", err)
+	}
+	if len(so.History) != maxHistory || so.History[maxHistory-1] != "more" {
+		t.Fatalf("expected restored history to be limited to %d lines : got %d", maxHistory, len(so.History))
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Uncertain is a measurement with a standard uncertainty, written like
+// 9.81+-0.02 or 9.81±0.02. Uncertainties are propagated to first order,
+// assuming that the errors of different values are independent.
+type Uncertain struct {
+	Val, Err float64
+}
+
+func (u Uncertain) String() string { return fmt.Sprintf("%v ± %v", u.Val, u.Err) }
+
+func (u Uncertain) literal() string { return fmt.Sprintf("%v+-%v", u.Val, u.Err) }
+
+func (Uncertain) kind() string { return "uncertain number" }
+
+func (u Uncertain) format(prec int) string {
+	return fmt.Sprintf("%.*g ± %.*g", prec, u.Val, prec, u.Err)
+}
+
+func (u Uncertain) arith(op byte, y Value, reversed bool) (Value, error) {
+	var w Uncertain
+	switch y := y.(type) {
+	case Uncertain:
+		w = y
+	default:
+		f, ok := asFloat(y)
+		if !ok {
+			return nil, errUnsupported
+		}
+		w = Uncertain{f, 0}
+	}
+	a, b := u, w
+	if reversed {
+		a, b = b, a
+	}
+	val, err := floatArith(op, a.Val, b.Val)
+	if err != nil {
+		return nil, err
+	}
+	// da and db are the partial derivatives of 'a op b' with respect to a and
+	// b.
+	var da, db float64
+	switch op {
+	case '+':
+		da, db = 1, 1
+	case '-':
+		da, db = 1, -1
+	case '*':
+		da, db = b.Val, a.Val
+	case '/':
+		da, db = 1/b.Val, -a.Val/(b.Val*b.Val)
+	case '%':
+		da, db = 1, -math.Trunc(a.Val/b.Val)
+	case '^':
+		da = b.Val * math.Pow(a.Val, b.Val-1)
+		if b.Err != 0 {
+			if a.Val <= 0 {
+				return nil, fmt.Errorf("cannot raise non-positive number to uncertain power")
+			}
+			db = val * math.Log(a.Val)
+		}
+	}
+	return Uncertain{val, math.Hypot(da*a.Err, db*b.Err)}, nil
+}
+
+func (u Uncertain) apply(fn function) (Value, error) {
+	val, err := fn.call(u.Val)
+	if err != nil {
+		return nil, err
+	}
+	if fn.keepsUnits {
+		// Rounding does not change how well the value is known.
+		return Uncertain{val, u.Err}, nil
+	}
+	if fn.deriv != nil {
+		return Uncertain{val, math.Abs(fn.deriv(u.Val)) * u.Err}, nil
+	}
+	f := func(x float64) (float64, error) { return fn.call(x) }
+	slope, err := derivative5(f, u.Val)
+	if err != nil {
+		// Near the edge of the domain, so try again with a smaller step.
+		slope, err = derivative(f, u.Val, 1e-8*math.Max(1, math.Abs(u.Val)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot propagate uncertainty through %s at %v", fn.name, u.Val)
+	}
+	return Uncertain{val, math.Abs(slope) * u.Err}, nil
+}
+
+// parseUncertain returns the uncertain number written as s and true, or false
+// if s is not an uncertain number.
+func parseUncertain(s string) (Uncertain, bool) {
+	val, err, found := strings.Cut(s, "+-")
+	if !found {
+		if val, err, found = strings.Cut(s, "±"); !found {
+			return Uncertain{}, false
+		}
+	}
+	v, e1 := strconv.ParseFloat(val, 64)
+	e, e2 := strconv.ParseFloat(err, 64)
+	if e1 != nil || e2 != nil || e < 0 {
+		return Uncertain{}, false
+	}
+	return Uncertain{v, e}, true
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUncertainArith(t *testing.T) {
+	tests := []struct {
+		op   byte
+		x, y Uncertain
+		want Uncertain
+	}{
+		{'+', Uncertain{3, 0.3}, Uncertain{4, 0.4}, Uncertain{7, 0.5}},
+		{'-', Uncertain{3, 0.3}, Uncertain{4, 0.4}, Uncertain{-1, 0.5}},
+		{'*', Uncertain{2, 0.1}, Uncertain{3, 0.1}, Uncertain{6, math.Hypot(0.3, 0.2)}},
+		{'/', Uncertain{6, 0.6}, Uncertain{2, 0}, Uncertain{3, 0.3}},
+		{'^', Uncertain{2, 0.1}, Uncertain{3, 0}, Uncertain{8, 1.2}},
+		{'^', Uncertain{2, 0}, Uncertain{3, 0.1}, Uncertain{8, 0.8 * math.Ln2}},
+	}
+	for _, test := range tests {
+		v, err := test.x.arith(test.op, test.y, false)
+		if err != nil {
+			t.Fatalf("%v %c %v : unexpected error %q", test.x, test.op, test.y, err)
+		}
+		got := v.(Uncertain)
+		if math.Abs(got.Val-test.want.Val) > 1e-12 || math.Abs(got.Err-test.want.Err) > 1e-12 {
+			t.Fatalf("%v %c %v : expected = %v : got = %v", test.x, test.op, test.y, test.want, got)
+		}
+	}
+	if _, err := (Uncertain{-1, 0.1}).arith('^', Uncertain{0.5, 0.1}, false); err == nil {
+		t.Fatal("expected error raising negative number to uncertain power")
+	}
+}
+
+func TestUncertainFunctions(t *testing.T) {
+	tests := []struct {
+		fn   function
+		x    Uncertain
+		want Uncertain
+	}{
+		// These have derivatives, so their uncertainties are exact.
+		{sine, Uncertain{0, 0.1}, Uncertain{0, 0.1}},
+		{ln, Uncertain{1, 0.1}, Uncertain{0, 0.1}},
+		{DegreeMode.forward(sine), Uncertain{60, 1}, Uncertain{math.Sqrt(3) / 2, 0.5 * math.Pi / 180}},
+		{DegreeMode.inverse(arcsine), Uncertain{0, 0.01}, Uncertain{0, 0.01 * 180 / math.Pi}},
+		{tanh, Uncertain{0, 0.2}, Uncertain{0, 0.2}},
+		// Rounding does not change the uncertainty.
+		{floor, Uncertain{1.5, 0.1}, Uncertain{1, 0.1}},
+	}
+	for _, test := range tests {
+		v, err := test.x.apply(test.fn)
+		if err != nil {
+			t.Fatalf("%s of %v : unexpected error %q", test.fn.name, test.x, err)
+		}
+		got := v.(Uncertain)
+		if math.Abs(got.Val-test.want.Val) > 1e-15 || math.Abs(got.Err-test.want.Err) > 1e-15 {
+			t.Fatalf("%s of %v : expected = %v : got = %v", test.fn.name, test.x, test.want, got)
+		}
+	}
+	// Gamma has no derivative, so its uncertainty is found numerically:
+	// gamma'(1) is minus the Euler-Mascheroni constant.
+	v, err := Uncertain{1, 0.1}.apply(gammaFn)
+	if got := v.(Uncertain); err != nil || math.Abs(got.Err-0.1*0.5772156649015329) > 1e-10 {
+		t.Fatalf("gamma of 1 ± 0.1 : expected uncertainty %v : got = %v, %v", 0.1*0.5772156649015329, v, err)
+	}
+	if _, err := (Uncertain{0, 0.1}).apply(ln); err == nil {
+		t.Fatal("expected error taking logarithm of 0 ± 0.1")
+	}
+}
+
+func TestUncertainLiteral(t *testing.T) {
+	u := Uncertain{-1.5, 0.25}
+	if s := u.String(); s != "-1.5 ± 0.25" {
+		t.Fatalf(`expected "-1.5 ± 0.25" : got = %q`, s)
+	}
+	got, ok := parseUncertain(Literal(u))
+	if !ok || got != u {
+		t.Fatalf("expected %q to parse back to %v : got = %v", Literal(u), u, got)
+	}
+}
@@ -0,0 +1,472 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// dimension holds the exponents of the SI base units m, kg, s, A, K, mol, and
+// cd, in that order.
+type dimension [7]int8
+
+var baseSymbols = [len(dimension{})]string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+func (d dimension) add(e dimension, sign int) (sum dimension) {
+	for i := range d {
+		sum[i] = d[i] + int8(sign)*e[i]
+	}
+	return sum
+}
+
+// String returns the dimension as a product of SI base units.
+func (d dimension) String() string {
+	u := Unit{factor: 1}
+	for i, n := range d {
+		if n != 0 {
+			u.terms = append(u.terms, unitTerm{baseSymbols[i], int(n)})
+		}
+	}
+	if len(u.terms) == 0 {
+		return ""
+	}
+	return u.String()
+}
+
+type unitDef struct {
+	sym        string
+	factor     float64
+	dim        dimension
+	prefixable bool
+}
+
+// builtinUnits is the unit database. Factors are the size of each unit in SI
+// base units.
+var builtinUnits = []unitDef{
+	{"m", 1, dimension{1}, true},
+	{"g", 1e-3, dimension{0, 1}, true},
+	{"s", 1, dimension{0, 0, 1}, true},
+	{"A", 1, dimension{0, 0, 0, 1}, true},
+	{"K", 1, dimension{0, 0, 0, 0, 1}, true},
+	{"mol", 1, dimension{0, 0, 0, 0, 0, 1}, true},
+	{"cd", 1, dimension{0, 0, 0, 0, 0, 0, 1}, true},
+	{"in", 0.0254, dimension{1}, false},
+	{"ft", 0.3048, dimension{1}, false},
+	{"yd", 0.9144, dimension{1}, false},
+	{"mi", 1609.344, dimension{1}, false},
+	{"nmi", 1852, dimension{1}, false},
+	{"au", 149597870700, dimension{1}, false},
+	{"ly", 9460730472580800, dimension{1}, false},
+	{"pc", 3.0856775814913673e16, dimension{1}, false},
+	{"ha", 1e4, dimension{2}, false},
+	{"acre", 4046.8564224, dimension{2}, false},
+	{"L", 1e-3, dimension{3}, true},
+	{"gal", 3.785411784e-3, dimension{3}, false},
+	{"lb", 0.45359237, dimension{0, 1}, false},
+	{"oz", 0.028349523125, dimension{0, 1}, false},
+	{"t", 1000, dimension{0, 1}, false},
+	{"min", 60, dimension{0, 0, 1}, false},
+	{"h", 3600, dimension{0, 0, 1}, false},
+	{"d", 86400, dimension{0, 0, 1}, false},
+	{"wk", 604800, dimension{0, 0, 1}, false},
+	{"yr", 31557600, dimension{0, 0, 1}, false},
+	{"mph", 0.44704, dimension{1, 0, -1}, false},
+	{"kn", 1852.0 / 3600, dimension{1, 0, -1}, false},
+	{"Hz", 1, dimension{0, 0, -1}, true},
+	{"N", 1, dimension{1, 1, -2}, true},
+	{"lbf", 4.4482216152605, dimension{1, 1, -2}, false},
+	{"Pa", 1, dimension{-1, 1, -2}, true},
+	{"bar", 1e5, dimension{-1, 1, -2}, true},
+	{"atm", 101325, dimension{-1, 1, -2}, false},
+	{"psi", 6894.757293168361, dimension{-1, 1, -2}, false},
+	{"J", 1, dimension{2, 1, -2}, true},
+	{"eV", 1.602176634e-19, dimension{2, 1, -2}, true},
+	{"cal", 4.184, dimension{2, 1, -2}, true},
+	{"Wh", 3600, dimension{2, 1, -2}, true},
+	{"W", 1, dimension{2, 1, -3}, true},
+	{"hp", 745.6998715822702, dimension{2, 1, -3}, false},
+	{"C", 1, dimension{0, 0, 1, 1}, true},
+	{"V", 1, dimension{2, 1, -3, -1}, true},
+	{"ohm", 1, dimension{2, 1, -3, -2}, true},
+	{"F", 1, dimension{-2, -1, 4, 2}, true},
+	{"T", 1, dimension{0, 1, -2, -1}, true},
+	{"Wb", 1, dimension{2, 1, -2, -1}, true},
+	{"H", 1, dimension{2, 1, -2, -2}, true},
+	{"R", 5.0 / 9, dimension{0, 0, 0, 0, 1}, false},
+}
+
+var unitIndex = func() map[string]int {
+	m := make(map[string]int, len(builtinUnits))
+	for i, def := range builtinUnits {
+		m[def.sym] = i
+	}
+	return m
+}()
+
+var unitPrefixes = []struct {
+	sym    string
+	factor float64
+}{
+	{"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"k", 1e3}, {"h", 1e2}, {"c", 1e-2},
+	{"d", 1e-1}, {"m", 1e-3}, {"u", 1e-6}, {"µ", 1e-6}, {"n", 1e-9}, {"p", 1e-12},
+	{"f", 1e-15},
+}
+
+// Unit is a product of unit symbols raised to integer powers.
+type Unit struct {
+	terms []unitTerm
+	// factor is the size of the unit in SI base units.
+	factor float64
+	dim    dimension
+}
+
+type unitTerm struct {
+	sym string
+	exp int
+}
+
+// mul returns the product of u and v raised to sign, which should be 1 or -1.
+func (u Unit) mul(v Unit, sign int) Unit {
+	terms := slices.Clone(u.terms)
+	for _, t := range v.terms {
+		i := slices.IndexFunc(terms, func(s unitTerm) bool { return s.sym == t.sym })
+		if i < 0 {
+			terms = append(terms, unitTerm{t.sym, sign * t.exp})
+			continue
+		}
+		terms[i].exp += sign * t.exp
+	}
+	terms = slices.DeleteFunc(terms, func(t unitTerm) bool { return t.exp == 0 })
+	return Unit{terms, u.factor * math.Pow(v.factor, float64(sign)), u.dim.add(v.dim, sign)}
+}
+
+// pow returns u raised to p, or an error if that would leave a non-integer
+// exponent on any symbol.
+func (u Unit) pow(p float64) (Unit, error) {
+	v := Unit{factor: math.Pow(u.factor, p)}
+	for _, t := range u.terms {
+		e := float64(t.exp) * p
+		if e != math.Trunc(e) {
+			return Unit{}, fmt.Errorf("cannot raise %s to non-integer power of units", u)
+		}
+		if e != 0 {
+			v.terms = append(v.terms, unitTerm{t.sym, int(e)})
+		}
+	}
+	for i, d := range u.dim {
+		v.dim[i] = int8(float64(d) * p)
+	}
+	return v, nil
+}
+
+func (u Unit) String() string {
+	sb := new(strings.Builder)
+	for _, t := range u.terms {
+		if t.exp > 0 {
+			if sb.Len() > 0 {
+				sb.WriteByte('*')
+			}
+			sb.WriteString(t.sym)
+			if t.exp != 1 {
+				sb.WriteString(fmt.Sprintf("^%d", t.exp))
+			}
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteByte('1')
+	}
+	for _, t := range u.terms {
+		if t.exp < 0 {
+			sb.WriteByte('/')
+			sb.WriteString(t.sym)
+			if t.exp != -1 {
+				sb.WriteString(fmt.Sprintf("^%d", -t.exp))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// isSymbol reports whether s can be used as the name of a unit.
+func isSymbol(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupUnit returns the size and dimension of the unit named sym, which may
+// be a built-in unit, a user-defined unit, or a prefixed built-in unit.
+func (so *StackOperator) lookupUnit(sym string) (factor float64, dim dimension, ok bool) {
+	if i, pres := unitIndex[sym]; pres {
+		return builtinUnits[i].factor, builtinUnits[i].dim, true
+	}
+	if v, pres := so.Units[sym]; pres {
+		switch v := v.(type) {
+		case Quantity:
+			return v.Val * v.Unit.factor, v.Unit.dim, true
+		case Number:
+			return float64(v), dimension{}, true
+		}
+	}
+	for _, p := range unitPrefixes {
+		rest, found := strings.CutPrefix(sym, p.sym)
+		if i, pres := unitIndex[rest]; found && pres && builtinUnits[i].prefixable {
+			return p.factor * builtinUnits[i].factor, builtinUnits[i].dim, true
+		}
+	}
+	return 0, dimension{}, false
+}
+
+// parseUnit parses a unit expression such as "km", "m/s^2", or "kg*m^2/s".
+// Every '/' divides by the single term that follows it.
+func (so *StackOperator) parseUnit(expr string) (u Unit, err error) {
+	u = Unit{factor: 1}
+	sign, start := 1, 0
+	for i := 0; i <= len(expr); i++ {
+		if i < len(expr) && expr[i] != '*' && expr[i] != '/' {
+			continue
+		}
+		term := expr[start:i]
+		if term != "1" || start != 0 || i == len(expr) {
+			t, err := so.parseUnitTerm(term)
+			if err != nil {
+				return Unit{}, err
+			}
+			u = u.mul(t, sign)
+		}
+		if i < len(expr) {
+			sign = 1
+			if expr[i] == '/' {
+				sign = -1
+			}
+		}
+		start = i + 1
+	}
+	return u, nil
+}
+
+func (so *StackOperator) parseUnitTerm(term string) (Unit, error) {
+	sym, sExp, hasExp := strings.Cut(term, "^")
+	exp := 1
+	if hasExp {
+		var err error
+		if exp, err = strconv.Atoi(sExp); err != nil || exp == 0 {
+			return Unit{}, fmt.Errorf("invalid exponent in unit %s", term)
+		}
+	}
+	factor, dim, ok := so.lookupUnit(sym)
+	if !ok {
+		return Unit{}, fmt.Errorf("unknown unit %s", sym)
+	}
+	u := Unit{[]unitTerm{{sym, 1}}, factor, dim}
+	return u.pow(float64(exp))
+}
+
+// Quantity is a number with units.
+type Quantity struct {
+	Val  float64
+	Unit Unit
+}
+
+// newQuantity returns val in units of u, or a plain Number if u is
+// dimensionless.
+func newQuantity(val float64, u Unit) Value {
+	if u.dim == (dimension{}) {
+		return Number(val * u.factor)
+	}
+	return Quantity{val, u}
+}
+
+func (q Quantity) String() string { return fmt.Sprintf("%v_%s", q.Val, q.Unit) }
+
+func (Quantity) kind() string { return "quantity" }
+
+func (q Quantity) format(prec int) string {
+	return fmt.Sprintf("%.*g_%s", prec, q.Val, q.Unit)
+}
+
+// in returns the magnitude of q in units of u, which must have the same
+// dimension.
+func (q Quantity) in(u Unit) float64 {
+	return q.Val * q.Unit.factor / u.factor
+}
+
+func (q Quantity) arith(op byte, y Value, reversed bool) (Value, error) {
+	switch y := y.(type) {
+	case Number:
+		f := float64(y)
+		switch {
+		case op == '*':
+			return newQuantity(q.Val*f, q.Unit), nil
+		case op == '/' && !reversed:
+			if f == 0 {
+				return nil, errors.New("cannot divide by 0")
+			}
+			return newQuantity(q.Val/f, q.Unit), nil
+		case op == '/':
+			if q.Val == 0 {
+				return nil, errors.New("cannot divide by 0")
+			}
+			return newQuantity(f/q.Val, Unit{factor: 1}.mul(q.Unit, -1)), nil
+		case op == '^' && !reversed:
+			u, err := q.Unit.pow(f)
+			if err != nil {
+				return nil, err
+			}
+			val, err := floatArith('^', q.Val, f)
+			return newQuantity(val, u), err
+		case op == '^':
+			return nil, fmt.Errorf("cannot raise to power with units %s", q.Unit)
+		}
+		return nil, fmt.Errorf("cannot %s %s and number: incompatible dimensions", opNames[op], q.Unit)
+	case Quantity:
+		x := q
+		if reversed {
+			x, y = y, x
+		}
+		switch op {
+		case '+', '-', '%':
+			if x.Unit.dim != y.Unit.dim {
+				return nil, fmt.Errorf("cannot %s %s and %s: incompatible dimensions", opNames[op], x.Unit, y.Unit)
+			}
+			val, err := floatArith(op, x.Val, y.in(x.Unit))
+			return newQuantity(val, x.Unit), err
+		case '*':
+			return newQuantity(x.Val*y.Val, x.Unit.mul(y.Unit, 1)), nil
+		case '/':
+			if y.Val == 0 {
+				return nil, errors.New("cannot divide by 0")
+			}
+			return newQuantity(x.Val/y.Val, x.Unit.mul(y.Unit, -1)), nil
+		case '^':
+			return nil, fmt.Errorf("cannot raise to power with units %s", y.Unit)
+		}
+	}
+	return nil, errUnsupported
+}
+
+func (q Quantity) apply(fn function) (Value, error) {
+	if !fn.keepsUnits {
+		return nil, fmt.Errorf("cannot take %s of value with units %s", fn.name, q.Unit)
+	}
+	f, err := fn.call(q.Val)
+	return Quantity{f, q.Unit}, err
+}
+
+// DefUnit adds a unit to StackOperator.Units with the name def[0] and the size
+// of the value left on top of a sub-stack after executing the rest of def. It
+// deletes def[0] from StackOperator.Units if len(def) == 1.
+func (so *StackOperator) DefUnit(def []string) (msg string, err error) {
+	name := def[0]
+	if !isSymbol(name) {
+		return "", errors.New(fmt.Sprintf("could not define %s : unit names may only contain letters\n", name))
+	}
+	if _, pres := unitIndex[name]; pres {
+		return "", errors.New(fmt.Sprintf("could not define %s : cannot redefine built-in unit\n", name))
+	}
+	if len(def) == 1 {
+		if _, pres := so.Units[name]; !pres {
+			return "", errors.New(fmt.Sprintf("could not delete %s : not defined\n", name))
+		}
+		delete(so.Units, name)
+		return fmt.Sprintf("deleted unit: %s\n", name), nil
+	}
+	tmp := so.subOperator()
+	err = tmp.ParseInput(strings.Join(def[1:], " "))
+	if err != nil {
+		return "", err
+	}
+	if len(tmp.Stack.Values) == 0 {
+		return "", nil
+	}
+	switch v := tmp.Stack.Values[len(tmp.Stack.Values)-1].(type) {
+	case Number, Quantity:
+		so.Units[name] = v
+		return fmt.Sprintf("defined unit %s = %v\n", name, v), nil
+	default:
+		return "", errors.New(fmt.Sprintf("could not define %s : %s is not a quantity\n", name, v.kind()))
+	}
+}
+
+// Convert is an Action with the following description: pop 'a', 'b'; push 'b'
+// converted to the units or time zone of 'a'.
+var Convert = &Action{
+	func(so *StackOperator) (string, error) {
+		target := so.Stack.Pop()
+		v := so.Stack.Pop()
+		if z, ok := target.(Zone); ok {
+			t, ok := v.(Time)
+			if !ok {
+				return "", so.Fail(fmt.Sprintf("cannot convert %s to time zone", v.kind()), v, target)
+			}
+			so.Stack.Push(Time{t.T.In(z.Loc)})
+			return so.Stack.Display(), nil
+		}
+		u := Unit{factor: 1}
+		switch t := target.(type) {
+		case Quantity:
+			u = t.Unit
+		case Number:
+		default:
+			return "", so.Fail(fmt.Sprintf("cannot convert to %s", target.kind()), v, target)
+		}
+		switch q := v.(type) {
+		case Quantity:
+			if q.Unit.dim != u.dim {
+				return "", so.Fail(fmt.Sprintf("cannot convert %s to %s: incompatible dimensions", q.Unit, u), v, target)
+			}
+			so.Stack.Push(newQuantity(q.in(u), u))
+		case Number:
+			if u.dim != (dimension{}) {
+				return "", so.Fail(fmt.Sprintf("cannot convert number to %s: incompatible dimensions", u), v, target)
+			}
+			so.Stack.Push(q)
+		default:
+			return "", so.Fail(fmt.Sprintf("cannot convert %s", v.kind()), v, target)
+		}
+		return so.Stack.Display(), nil
+	}, 2, 1,
+	"Pop 'a', 'b'; push 'b' converted to the units or time zone of 'a'.",
+}
+
+// Units is an Action with the following description: display all defined
+// units.
+var Units = &Action{
+	func(so *StackOperator) (string, error) {
+		keys := make([]string, 0, len(so.Units))
+		for k := range so.Units {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		sb := new(strings.Builder)
+		for _, k := range keys {
+			sb.WriteString(fmt.Sprintf("%8s = %v\n", k, so.Units[k]))
+		}
+		for _, def := range builtinUnits {
+			s := fmt.Sprint(def.factor)
+			if dim := def.dim.String(); dim != "" {
+				s += "_" + dim
+			}
+			var extra string
+			if def.prefixable {
+				extra = " (prefixable)"
+			}
+			sb.WriteString(fmt.Sprintf("%8s = %s%s\n", def.sym, s, extra))
+		}
+		return sb.String(), nil
+	}, 0, 0,
+	"Display all defined units.",
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 Josh Tompkin
+// Licensed under the MIT License
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Value is anything that can be stored in a Stack.
+type Value interface {
+	// String returns the representation of the value shown to the user. It
+	// should be parseable as a literal whenever possible.
+	String() string
+	// kind returns a short description of what sort of value this is, for use
+	// in error messages.
+	kind() string
+}
+
+// literaler is implemented by Values whose String cannot be entered again.
+type literaler interface {
+	// literal returns the value written so that entering it pushes the value
+	// again.
+	literal() string
+}
+
+// Literal returns v written so that entering it pushes v again.
+func Literal(v Value) string {
+	if l, ok := v.(literaler); ok {
+		return l.literal()
+	}
+	return v.String()
+}
+
+// Number is a plain real number.
+type Number float64
+
+func (n Number) String() string { return fmt.Sprint(float64(n)) }
+
+func (Number) kind() string { return "number" }
+
+func (n Number) arith(op byte, y Value, reversed bool) (Value, error) {
+	m, ok := y.(Number)
+	if !ok {
+		return nil, errUnsupported
+	}
+	a, b := float64(n), float64(m)
+	if reversed {
+		a, b = b, a
+	}
+	f, err := floatArith(op, a, b)
+	return Number(f), err
+}
+
+func (n Number) apply(fn function) (Value, error) {
+	f, err := fn.call(float64(n))
+	return Number(f), err
+}
+
+// operand is implemented by values that support the arithmetic operators. arith
+// returns the result of 'v op y' where v is the receiver, or 'y op v' if
+// reversed is true. It returns errUnsupported if it does not know how to
+// combine v with y, so that y gets a chance to try.
+type operand interface {
+	Value
+	arith(op byte, y Value, reversed bool) (Value, error)
+}
+
+// applier is implemented by values that real functions of one variable can be
+// applied to.
+type applier interface {
+	Value
+	apply(fn function) (Value, error)
+}
+
+// formatter is implemented by values that can be shown with a limited number of
+// significant digits.
+type formatter interface {
+	format(prec int) string
+}
+
+var errUnsupported = errors.New("unsupported operation")
+
+var opNames = map[byte]string{
+	'+': "add",
+	'-': "subtract",
+	'*': "multiply",
+	'/': "divide",
+	'%': "take remainder of",
+	'^': "raise",
+}
+
+// arith returns the result of 'x op y', where op is one of + - * / % ^.
+func arith(op byte, x, y Value) (Value, error) {
+	if a, ok := x.(operand); ok {
+		if v, err := a.arith(op, y, false); err != errUnsupported {
+			return v, err
+		}
+	}
+	if b, ok := y.(operand); ok {
+		if v, err := b.arith(op, x, true); err != errUnsupported {
+			return v, err
+		}
+	}
+	return nil, fmt.Errorf("cannot %s %s and %s", opNames[op], x.kind(), y.kind())
+}
+
+// floatArith returns the result of 'x op y' for plain numbers.
+func floatArith(op byte, x, y float64) (float64, error) {
+	switch op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	case '/':
+		if y == 0 {
+			return 0, errors.New("cannot divide by 0")
+		}
+		return x / y, nil
+	case '%':
+		if y == 0 {
+			return 0, errors.New("cannot divide by 0")
+		}
+		return math.Mod(x, y), nil
+	case '^':
+		if x == 0 && y < 0 {
+			return 0, errors.New("cannot raise 0 to negative power")
+		}
+		if x < 0 && y != float64(int(y)) {
+			return 0, errors.New("cannot raise negative number to non-integer power")
+		}
+		return math.Pow(x, y), nil
+	}
+	return 0, errUnsupported
+}
+
+// function is a real function of one variable that can be applied to any
+// Value that implements applier.
+type function struct {
+	name string
+	f    func(float64) float64
+	// domain returns a message describing why x is outside of the domain of
+	// f, or an empty string if it is not. It may be nil if f is defined
+	// everywhere.
+	domain func(x float64) string
+	// keepsUnits signifies that f can be applied to the magnitude of a
+	// quantity without changing its units.
+	keepsUnits bool
+	// deriv returns the derivative of f at x, for propagating uncertainties.
+	// It may be nil, and then the derivative is found numerically.
+	deriv func(x float64) float64
+	// poles signifies that f, a trigonometric function of radians, is
+	// undefined at odd multiples of a right angle.
+	poles bool
+}
+
+// call returns f(x), or an error if x is outside of the domain of f.
+func (fn function) call(x float64) (float64, error) {
+	if fn.domain != nil {
+		if msg := fn.domain(x); msg != "" {
+			return 0, errors.New(msg)
+		}
+	}
+	return fn.f(x), nil
+}
+
+// apply returns the result of applying fn to v.
+func apply(fn function, v Value) (Value, error) {
+	if a, ok := v.(applier); ok {
+		return a.apply(fn)
+	}
+	return nil, fmt.Errorf("cannot take %s of %s", fn.name, v.kind())
+}
+
+// formatValue returns v with at most prec significant digits if v supports it,
+// or v.String() if it does not.
+func formatValue(v Value, prec int) string {
+	switch v := v.(type) {
+	case Number:
+		return fmt.Sprintf("%.*g", prec, float64(v))
+	case formatter:
+		return v.format(prec)
+	}
+	return v.String()
+}
+
+// asFloat returns v as a plain number if v is a Number or an Int.
+func asFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case Number:
+		return float64(v), true
+	case Int:
+		return v.float(), true
+	}
+	return 0, false
+}
+
+// floats returns values as plain numbers, or an error if any of them is not a
+// number.
+func floats(values []Value) ([]float64, error) {
+	fs := make([]float64, len(values))
+	for i, v := range values {
+		f, ok := asFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %s %v", v.kind(), v)
+		}
+		fs[i] = f
+	}
+	return fs, nil
+}
+
+// popFloat pops 'a', which must be a number. The stack is left untouched if it
+// is not.
+func (so *StackOperator) popFloat() (float64, error) {
+	fs, err := so.popFloats(1)
+	if err != nil {
+		return 0, err
+	}
+	return fs[0], nil
+}
+
+// popFloats pops n values that must all be numbers and returns them in the
+// order they were pushed. The stack is left untouched if any of them is not a
+// number.
+func (so *StackOperator) popFloats(n int) ([]float64, error) {
+	l := len(so.Stack.Values)
+	fs, err := floats(so.Stack.Values[l-n:])
+	if err != nil {
+		return nil, err
+	}
+	so.Stack.Values = so.Stack.Values[:l-n]
+	return fs, nil
+}
+
+// unary pops 'a' and pushes the result of applying fn to 'a'.
+func (so *StackOperator) unary(fn function) (string, error) {
+	x := so.Stack.Pop()
+	y, err := apply(fn, x)
+	if err != nil {
+		return "", so.Fail(err.Error(), x)
+	}
+	so.Stack.Push(y)
+	return so.Stack.Display(), nil
+}
+
+// binary pops 'a', 'b' and pushes the result of 'b' op 'a'.
+func (so *StackOperator) binary(op byte) (string, error) {
+	y := so.Stack.Pop()
+	x := so.Stack.Pop()
+	z, err := arith(op, x, y)
+	if err != nil {
+		return "", so.Fail(err.Error(), x, y)
+	}
+	so.Stack.Push(z)
+	return so.Stack.Display(), nil
+}